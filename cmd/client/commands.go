@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/pkg/client"
+)
+
+const defaultBatchSize = 500
+
+func (app *cli) shortenFlags(fs *flag.FlagSet) {
+	fs.StringVar(&app.alias, "alias", "", "Custom short ID, only for a single URL")
+	fs.DurationVar(&app.ttl, "ttl", 0, "Lifetime of the short URLs")
+	fs.StringVar(&app.expiresAt, "expires-at", "", "Expiration time of the short URLs in RFC 3339")
+}
+
+func (app *cli) batchFlags(fs *flag.FlagSet) {
+	fs.IntVar(&app.batchSize, "batch-size", defaultBatchSize, "Number of URLs sent in one request")
+	fs.DurationVar(&app.ttl, "ttl", 0, "Lifetime of the short URLs without expires_at")
+}
+
+func (app *cli) deleteFlags(fs *flag.FlagSet) {
+	fs.IntVar(&app.batchSize, "batch-size", defaultBatchSize, "Number of IDs sent in one request")
+}
+
+func (app *cli) exportFlags(fs *flag.FlagSet) {
+	fs.StringVar(&app.format, "format", "ndjson", "Export format: ndjson or csv")
+}
+
+// inputs возвращает аргументы команды, а без аргументов — непустые строки
+// стандартного ввода.
+func (app *cli) inputs(args []string) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+	var lines []string
+	scanner := bufio.NewScanner(app.stdin)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read stdin: %w", err)
+	}
+	return lines, nil
+}
+
+func (app *cli) parseExpiresAt() (*time.Time, error) {
+	if app.expiresAt == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, app.expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("%w: -expires-at: %v", errUsage, err)
+	}
+	return &t, nil
+}
+
+type shortenResult struct {
+	URL      string `json:"url"`
+	ShortURL string `json:"short_url,omitempty"`
+	Existing bool   `json:"existing,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// shorten сокращает ссылки по одной. Ошибка по одной ссылке не прерывает
+// остальные, но команда завершается с ошибкой. Уже сокращённая ссылка
+// ошибкой не считается.
+func (app *cli) shorten(ctx context.Context, args []string) error {
+	expiresAt, err := app.parseExpiresAt()
+	if err != nil {
+		return err
+	}
+	urls, err := app.inputs(args)
+	if err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("%w: no URLs given", errUsage)
+	}
+	if app.alias != "" && len(urls) > 1 {
+		return fmt.Errorf("%w: -alias needs exactly one URL", errUsage)
+	}
+
+	results := make([]shortenResult, 0, len(urls))
+	failed := 0
+	for _, u := range urls {
+		shortURL, err := app.client.Shorten(ctx, client.ShortenRequest{
+			URL:       u,
+			Alias:     app.alias,
+			ExpiresAt: expiresAt,
+			TTL:       app.ttl,
+		})
+		existing, isConflict := client.IsConflict(err)
+		switch {
+		case isConflict:
+			results = append(results, shortenResult{URL: u, ShortURL: existing, Existing: true})
+		case err != nil:
+			if ctx.Err() != nil {
+				return err
+			}
+			failed++
+			fmt.Fprintf(app.stderr, "shorten: %s: %v\n", u, err)
+			results = append(results, shortenResult{URL: u, Error: err.Error()})
+		default:
+			results = append(results, shortenResult{URL: u, ShortURL: shortURL})
+		}
+	}
+
+	if err := app.writeShortenResults(results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d URLs failed", failed, len(urls))
+	}
+	return nil
+}
+
+func (app *cli) writeShortenResults(results []shortenResult) error {
+	switch app.output {
+	case outputJSON:
+		return writeJSON(app.stdout, results)
+	case outputTable:
+		rows := make([][]string, 0, len(results))
+		for _, r := range results {
+			status := "created"
+			if r.Existing {
+				status = "existing"
+			} else if r.Error != "" {
+				status = "error: " + r.Error
+			}
+			rows = append(rows, []string{r.URL, r.ShortURL, status})
+		}
+		return app.writeTable([]string{"URL", "SHORT URL", "STATUS"}, rows)
+	}
+	// в формате plain ошибки уже выведены в stderr
+	for _, r := range results {
+		if r.Error == "" {
+			if _, err := fmt.Fprintln(app.stdout, r.ShortURL); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type batchResult struct {
+	CorrelationID string `json:"correlation_id"`
+	OriginalURL   string `json:"original_url"`
+	ShortURL      string `json:"short_url"`
+}
+
+// batch сокращает ссылки из CSV с заголовком. Обязателен столбец
+// original_url (или url); без correlation_id строки нумеруются по порядку.
+func (app *cli) batch(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expected one CSV file or - for stdin", errUsage)
+	}
+	if app.batchSize <= 0 {
+		return fmt.Errorf("%w: -batch-size must be positive", errUsage)
+	}
+	in := app.stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	items, err := readBatch(in, app.ttl)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return errors.New("CSV has no URLs")
+	}
+
+	results := make([]batchResult, 0, len(items))
+	for start := 0; start < len(items); start += app.batchSize {
+		chunk := items[start:min(start+app.batchSize, len(items))]
+		urls, err := app.client.ShortenBatch(ctx, chunk)
+		if err != nil {
+			err = fmt.Errorf("rows %d-%d: %w", start+1, start+len(chunk), err)
+			// уже сокращённые ссылки выводятся, чтобы не потерять их при повторе
+			if len(results) > 0 {
+				if werr := app.writeBatchResults(results); werr != nil {
+					return errors.Join(err, fmt.Errorf("write results: %w", werr))
+				}
+			}
+			return err
+		}
+		for _, item := range chunk {
+			results = append(results, batchResult{
+				CorrelationID: item.CorrelationID,
+				OriginalURL:   item.URL,
+				ShortURL:      urls[item.CorrelationID],
+			})
+		}
+	}
+	return app.writeBatchResults(results)
+}
+
+func readBatch(r io.Reader, ttl time.Duration) ([]client.BatchItem, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["original_url"]; !ok {
+		i, ok := columns["url"]
+		if !ok {
+			return nil, errors.New("CSV header must contain an original_url column")
+		}
+		columns["original_url"] = i
+	}
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var items []client.BatchItem
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		item := client.BatchItem{
+			CorrelationID: field(row, "correlation_id"),
+			ShortenRequest: client.ShortenRequest{
+				URL:   field(row, "original_url"),
+				Alias: field(row, "alias"),
+			},
+		}
+		if item.CorrelationID == "" {
+			item.CorrelationID = strconv.Itoa(len(items) + 1)
+		}
+		if s := field(row, "expires_at"); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: expires_at: %w", line, err)
+			}
+			item.ExpiresAt = &t
+		} else {
+			item.TTL = ttl
+		}
+		items = append(items, item)
+	}
+}
+
+func (app *cli) writeBatchResults(results []batchResult) error {
+	if app.output == outputJSON {
+		return writeJSON(app.stdout, results)
+	}
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		rows = append(rows, []string{r.CorrelationID, r.OriginalURL, r.ShortURL})
+	}
+	return app.writeTable([]string{"CORRELATION ID", "URL", "SHORT URL"}, rows)
+}
+
+func (app *cli) resolve(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expected one short ID or URL", errUsage)
+	}
+	originalURL, err := app.client.Resolve(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	switch app.output {
+	case outputJSON:
+		return writeJSON(app.stdout, map[string]string{"id": args[0], "original_url": originalURL})
+	case outputTable:
+		return app.writeTable([]string{"ID", "URL"}, [][]string{{args[0], originalURL}})
+	}
+	_, err = fmt.Fprintln(app.stdout, originalURL)
+	return err
+}
+
+func (app *cli) list(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("%w: list takes no arguments", errUsage)
+	}
+	urls, err := app.client.List(ctx)
+	if err != nil {
+		return err
+	}
+	if app.output == outputJSON {
+		return writeJSON(app.stdout, urls)
+	}
+	rows := make([][]string, 0, len(urls))
+	for _, u := range urls {
+		rows = append(rows, []string{u.ShortURL, u.OriginalURL})
+	}
+	return app.writeTable([]string{"SHORT URL", "URL"}, rows)
+}
+
+// delete только ставит ссылки в очередь на удаление: сервис удаляет их
+// асинхронно.
+func (app *cli) delete(ctx context.Context, args []string) error {
+	ids, err := app.inputs(args)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("%w: no IDs given", errUsage)
+	}
+	if app.batchSize <= 0 {
+		return fmt.Errorf("%w: -batch-size must be positive", errUsage)
+	}
+
+	for start := 0; start < len(ids); start += app.batchSize {
+		chunk := ids[start:min(start+app.batchSize, len(ids))]
+		if err := app.client.Delete(ctx, chunk...); err != nil {
+			err = fmt.Errorf("IDs %d-%d: %w", start+1, start+len(chunk), err)
+			// принятые части выводятся, чтобы повторить только остаток
+			if app.output == outputJSON && start > 0 {
+				if werr := writeJSON(app.stdout, map[string][]string{"accepted": ids[:start]}); werr != nil {
+					return errors.Join(err, fmt.Errorf("write results: %w", werr))
+				}
+			}
+			return err
+		}
+	}
+	if app.output == outputJSON {
+		return writeJSON(app.stdout, map[string][]string{"accepted": ids})
+	}
+	return nil
+}
+
+func (app *cli) stats(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expected one short ID or URL", errUsage)
+	}
+	stats, err := app.client.Stats(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if app.output == outputJSON {
+		return writeJSON(app.stdout, stats)
+	}
+	rows := [][]string{
+		{"total_clicks", strconv.Itoa(stats.TotalClicks)},
+		{"unique_visitors", strconv.Itoa(stats.UniqueVisitors)},
+	}
+	for _, day := range stats.ClicksPerDay {
+		rows = append(rows, []string{"day " + day.Date, strconv.Itoa(day.Clicks)})
+	}
+	for _, ref := range stats.TopReferrers {
+		rows = append(rows, []string{"referrer " + ref.Referrer, strconv.Itoa(ref.Clicks)})
+	}
+	return app.writeTable([]string{"METRIC", "VALUE"}, rows)
+}
+
+func (app *cli) export(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("%w: export takes no arguments", errUsage)
+	}
+	var format client.ExportFormat
+	switch app.format {
+	case "ndjson":
+		format = client.ExportNDJSON
+	case "csv":
+		format = client.ExportCSV
+	default:
+		return fmt.Errorf("%w: unknown export format %q", errUsage, app.format)
+	}
+	return app.client.Export(ctx, app.stdout, format)
+}
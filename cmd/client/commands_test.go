@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBatch(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		csv           string
+		expectedItems []client.BatchItem
+		expectedError string
+	}{
+		{
+			name: "All columns",
+			csv:  "correlation_id,original_url,alias,expires_at\na,https://example.com/1,promo,2030-01-02T03:04:05Z\n",
+			expectedItems: []client.BatchItem{
+				{CorrelationID: "a", ShortenRequest: client.ShortenRequest{URL: "https://example.com/1", Alias: "promo", ExpiresAt: &expiresAt}},
+			},
+		},
+		{
+			name: "url header alias and numbered rows",
+			csv:  "url\nhttps://example.com/1\n https://example.com/2 \n",
+			expectedItems: []client.BatchItem{
+				{CorrelationID: "1", ShortenRequest: client.ShortenRequest{URL: "https://example.com/1", TTL: time.Hour}},
+				{CorrelationID: "2", ShortenRequest: client.ShortenRequest{URL: "https://example.com/2", TTL: time.Hour}},
+			},
+		},
+		{
+			name: "Short rows",
+			csv:  "original_url,correlation_id\nhttps://example.com/1\n",
+			expectedItems: []client.BatchItem{
+				{CorrelationID: "1", ShortenRequest: client.ShortenRequest{URL: "https://example.com/1", TTL: time.Hour}},
+			},
+		},
+		{name: "No URL column", csv: "link\nhttps://example.com/1\n", expectedError: "original_url column"},
+		{name: "Empty input", csv: "", expectedError: "read CSV header"},
+		{name: "Bad expires_at", csv: "url,expires_at\nhttps://example.com/1,\nhttps://example.com/2,tomorrow\n", expectedError: "line 3: expires_at"},
+		{name: "Header only", csv: "url\n", expectedItems: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			items, err := readBatch(strings.NewReader(tc.csv), time.Hour)
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedItems, items)
+		})
+	}
+}
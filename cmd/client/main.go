@@ -1,56 +1,221 @@
+// Команда client работает с сервисом сокращения ссылок из командной строки.
+//
+//	client [флаги] <команда> [флаги команды] [аргументы]
+//
+// Команды: shorten, batch, resolve, list, delete, stats, export. Общие
+// флаги можно указывать как до команды, так и после неё. Адрес сервиса
+// и токен по умолчанию берутся из SHORTENER_SERVER и SHORTENER_TOKEN.
+// Код выхода 1 означает ошибку запроса, 2 — ошибку в аргументах.
 package main
 
 import (
-	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"net/http"
-	"net/url"
 	"os"
-	//"strconv"
-	"strings"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/pkg/client"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
 )
 
+// errUsage — ошибка в аргументах команды; вместе с ней выводится справка.
+var errUsage = errors.New("usage error")
+
+type command struct {
+	name  string
+	args  string
+	usage string
+	run   func(app *cli, ctx context.Context, args []string) error
+	// flags регистрирует флаги команды
+	flags func(app *cli, fs *flag.FlagSet)
+	// creates — команда создаёт ссылки от имени пользователя
+	creates bool
+}
+
+var commands = []command{
+	{name: "shorten", args: "[url...]", usage: "Shorten URLs given as arguments or read line by line from stdin", run: (*cli).shorten, flags: (*cli).shortenFlags, creates: true},
+	{name: "batch", args: "<file.csv|->", usage: "Shorten URLs from a CSV file with original_url, correlation_id, alias and expires_at columns", run: (*cli).batch, flags: (*cli).batchFlags, creates: true},
+	{name: "resolve", args: "<id>", usage: "Print the original URL for a short ID or URL", run: (*cli).resolve},
+	{name: "list", usage: "List URLs shortened by the user", run: (*cli).list},
+	{name: "delete", args: "[id...]", usage: "Delete the user's URLs given as arguments or read from stdin", run: (*cli).delete, flags: (*cli).deleteFlags},
+	{name: "stats", args: "<id>", usage: "Show click statistics for the user's short URL", run: (*cli).stats},
+	{name: "export", usage: "Export the user's URLs to stdout", run: (*cli).export, flags: (*cli).exportFlags},
+}
+
+// cli хранит общие флаги и потоки ввода-вывода.
+type cli struct {
+	server  string
+	token   string
+	output  string
+	gzip    bool
+	timeout time.Duration
+
+	// флаги команд
+	alias     string
+	ttl       time.Duration
+	expiresAt string
+	batchSize int
+	format    string
+
+	stdin          io.Reader
+	stdout, stderr io.Writer
+
+	client *client.Client
+}
+
+func (app *cli) globalFlags(fs *flag.FlagSet) {
+	fs.StringVar(&app.server, "server", app.server, "Shortener base URL (env SHORTENER_SERVER)")
+	fs.StringVar(&app.token, "token", app.token, "Auth token of the user, defaults to SHORTENER_TOKEN")
+	fs.StringVar(&app.output, "output", app.output, "Output format: plain, table or json")
+	fs.BoolVar(&app.gzip, "gzip", app.gzip, "Compress requests and responses")
+	fs.DurationVar(&app.timeout, "timeout", app.timeout, "Timeout of the whole command, 0 disables it")
+}
+
+func (app *cli) usage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintln(app.stderr, "Usage: client [flags] <command> [command flags] [args]")
+		fmt.Fprintln(app.stderr, "\nCommands:")
+		for _, cmd := range commands {
+			fmt.Fprintf(app.stderr, "  %-8s %-12s %s\n", cmd.name, cmd.args, cmd.usage)
+		}
+		fmt.Fprintln(app.stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+}
+
 func main() {
-	endpoint := "http://localhost:8080/"
-	// контейнер данных для запроса
-	data := url.Values{}
-	// приглашение в консоли
-	fmt.Println("Введите длинный URL")
-	// открываем потоковое чтение из консоли
-	reader := bufio.NewReader(os.Stdin)
-	// читаем строку из консоли
-	long, err := reader.ReadString('\n')
-	if err != nil {
-		panic(err)
-	}
-	long = strings.TrimSuffix(long, "\n")
-	// заполняем контейнер данными
-	data.Set("url", long)
-	// добавляем HTTP-клиент
-	client := &http.Client{}
-	// пишем запрос
-	// запрос методом POST должен, помимо заголовков, содержать тело
-	// тело должно быть источником потокового чтения io.Reader
-	request, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(data.Encode()))
-	if err != nil {
-		panic(err)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	app := &cli{
+		server:  "http://localhost:8080",
+		output:  outputPlain,
+		timeout: 30 * time.Second,
+		stdin:   stdin,
+		stdout:  stdout,
+		stderr:  stderr,
+	}
+	if s := os.Getenv("SHORTENER_SERVER"); s != "" {
+		app.server = s
+	}
+
+	global := flag.NewFlagSet("client", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	app.globalFlags(global)
+	global.Usage = app.usage(global)
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if global.NArg() == 0 {
+		global.Usage()
+		return exitUsage
+	}
+
+	name := global.Arg(0)
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "unknown command %q\n", name)
+		global.Usage()
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	app.globalFlags(fs)
+	if cmd.flags != nil {
+		cmd.flags(app, fs)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: client %s [flags] %s\n\n%s.\n\nFlags:\n", cmd.name, cmd.args, cmd.usage)
+		fs.PrintDefaults()
 	}
-	// в заголовках запроса указываем кодировку
-	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	// отправляем запрос и получаем ответ
-	response, err := client.Do(request)
+	cmdArgs, err := parseInterspersed(fs, global.Args()[1:])
 	if err != nil {
-		panic(err)
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
 	}
-	// выводим код ответа
-	fmt.Println("Статус-код ", response.Status)
-	defer response.Body.Close()
-	// читаем поток из тела ответа
-	body, err := io.ReadAll(response.Body)
+	// токен из окружения не показывается в справке
+	if app.token == "" {
+		app.token = os.Getenv("SHORTENER_TOKEN")
+	}
+	switch app.output {
+	case outputPlain, outputTable, outputJSON:
+	default:
+		fmt.Fprintf(stderr, "unknown output format %q: use plain, table or json\n", app.output)
+		return exitUsage
+	}
+
+	c, err := client.New(app.server, client.WithToken(app.token), client.WithGzip(app.gzip))
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	app.client = c
+
+	if app.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, app.timeout)
+		defer cancel()
+	}
+	err = cmd.run(app, ctx, cmdArgs)
+	// без токена сервис выдаёт новый; его нужно сохранить, чтобы потом
+	// работать с созданными ссылками
+	if cmd.creates && app.token == "" && c.Token() != "" {
+		fmt.Fprintf(stderr, "auth token: %s\n", c.Token())
+	}
+	switch {
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "%s: %v\n", cmd.name, err)
+		fs.Usage()
+		return exitUsage
+	case err != nil:
+		fmt.Fprintf(stderr, "%s: %v\n", cmd.name, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// parseInterspersed разбирает флаги, стоящие в любом месте среди
+// аргументов, и возвращает остальные аргументы. После -- все аргументы
+// считаются позиционными.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
 	}
-	// и печатаем его
-	fmt.Println(string(body))
 }
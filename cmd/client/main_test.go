@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/app"
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer(t *testing.T, opts ...app.Option) *httptest.Server {
+	t.Helper()
+	// базовый адрес сервиса известен только после запуска сервера
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	mux.Handle("/", app.RootRouter(storage.InitMemoryStore(), ts.URL, opts...))
+	return ts
+}
+
+func TestParseInterspersed(t *testing.T) {
+	testCases := []struct {
+		name               string
+		args               []string
+		expectedPositional []string
+		expectedOutput     string
+		expectedAlias      string
+	}{
+		{name: "No args", args: nil, expectedPositional: nil, expectedOutput: "plain"},
+		{name: "Flags before args", args: []string{"-output", "json", "a", "b"}, expectedPositional: []string{"a", "b"}, expectedOutput: "json"},
+		{name: "Flags after args", args: []string{"a", "-output=table", "b", "-alias", "x"}, expectedPositional: []string{"a", "b"}, expectedOutput: "table", expectedAlias: "x"},
+		{name: "Double dash", args: []string{"a", "--", "-output", "json"}, expectedPositional: []string{"a", "-output", "json"}, expectedOutput: "plain"},
+		{name: "Single dash is positional", args: []string{"-", "-output", "json"}, expectedPositional: []string{"-"}, expectedOutput: "json"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := &cli{output: outputPlain}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			app.globalFlags(fs)
+			app.shortenFlags(fs)
+
+			positional, err := parseInterspersed(fs, tc.args)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedPositional, positional)
+			assert.Equal(t, tc.expectedOutput, app.output)
+			assert.Equal(t, tc.expectedAlias, app.alias)
+		})
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	_, err := parseInterspersed(fs, []string{"a", "-unknown"})
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	t.Setenv("SHORTENER_TOKEN", "")
+	ts := newServer(t)
+
+	testCases := []struct {
+		name         string
+		args         []string
+		stdin        string
+		expectedCode int
+		// expectedStdout — строки, которые должны быть в выводе
+		expectedStdout []string
+		expectedStderr string
+	}{
+		{name: "No command", args: nil, expectedCode: exitUsage, expectedStderr: "Usage: client"},
+		{name: "Help", args: []string{"-h"}, expectedCode: exitOK},
+		{name: "Command help", args: []string{"shorten", "-h"}, expectedCode: exitOK, expectedStderr: "Usage: client shorten"},
+		{name: "Unknown command", args: []string{"unknown"}, expectedCode: exitUsage, expectedStderr: `unknown command "unknown"`},
+		{name: "Unknown flag", args: []string{"list", "-unknown"}, expectedCode: exitUsage},
+		{name: "Unknown output", args: []string{"-output", "xml", "list"}, expectedCode: exitUsage, expectedStderr: `unknown output format "xml"`},
+		{name: "Missing argument", args: []string{"resolve"}, expectedCode: exitUsage, expectedStderr: "expected one short ID or URL"},
+		{name: "Bad expires-at", args: []string{"shorten", "-expires-at", "tomorrow", "https://example.com/"}, expectedCode: exitUsage},
+		{name: "Alias for many URLs", args: []string{"shorten", "-alias", "x", "https://example.com/1", "https://example.com/2"}, expectedCode: exitUsage},
+		{name: "Shorten", args: []string{"shorten", "https://example.com/a"}, expectedCode: exitOK,
+			expectedStdout: []string{ts.URL + "/"}, expectedStderr: "auth token: "},
+		{name: "Already shortened", args: []string{"shorten", "https://example.com/a", "-output", "json"}, expectedCode: exitOK,
+			expectedStdout: []string{`"url": "https://example.com/a"`, `"short_url": "` + ts.URL + "/"}},
+		{name: "Shorten from stdin", args: []string{"shorten"}, stdin: "https://example.com/b\n\n# comment\nhttps://example.com/c\n", expectedCode: exitOK,
+			expectedStdout: []string{ts.URL + "/", "\n" + ts.URL + "/"}},
+		{name: "Empty stdin", args: []string{"shorten"}, stdin: "\n", expectedCode: exitUsage, expectedStderr: "no URLs given"},
+		{name: "Invalid URL", args: []string{"shorten", "https://example.com/d", "ftp://example.com/"}, expectedCode: exitError,
+			expectedStdout: []string{ts.URL + "/"}, expectedStderr: "1 of 2 URLs failed"},
+		{name: "Unknown ID", args: []string{"resolve", "missing"}, expectedCode: exitError, expectedStderr: "not_found"},
+		{name: "Batch", args: []string{"batch", "-"}, stdin: "url,alias\nhttps://example.com/e,spring\n", expectedCode: exitOK,
+			expectedStdout: []string{"1\thttps://example.com/e\t" + ts.URL + "/spring"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"-server", ts.URL}, tc.args...)
+			code := run(context.Background(), args, strings.NewReader(tc.stdin), &stdout, &stderr)
+
+			assert.Equal(t, tc.expectedCode, code, stderr.String())
+			for _, s := range tc.expectedStdout {
+				assert.Contains(t, stdout.String(), s)
+			}
+			assert.Contains(t, stderr.String(), tc.expectedStderr)
+		})
+	}
+}
+
+// failingWriter отказывает в записи.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestBatchPartialFailure(t *testing.T) {
+	t.Setenv("SHORTENER_TOKEN", "")
+	ts := newServer(t)
+	csv := "original_url\nhttps://example.com/1\nftp://example.com/2\n"
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"-server", ts.URL, "batch", "-batch-size", "1", "-"},
+		strings.NewReader(csv), &stdout, &stderr)
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stdout.String(), "https://example.com/1", "links shortened before the failure must be printed")
+	assert.Contains(t, stderr.String(), "rows 2-2")
+
+	// ошибка вывода уже сокращённых ссылок не теряется
+	stderr.Reset()
+	code = run(context.Background(), []string{"-server", ts.URL, "batch", "-batch-size", "1", "-"},
+		strings.NewReader(csv), failingWriter{}, &stderr)
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "rows 2-2")
+	assert.Contains(t, stderr.String(), "write results")
+}
+
+func TestDeleteChunks(t *testing.T) {
+	t.Setenv("SHORTENER_TOKEN", "")
+	ts := newServer(t, app.WithBodyLimits(app.BodyLimits{MaxBatchItems: 2}))
+	ids := "a\nb\nc\nd\ne\n"
+
+	// удалять ссылки может только пользователь с токеном
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"-server", ts.URL, "shorten", "https://example.com/"},
+		strings.NewReader(""), &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+	token, ok := strings.CutPrefix(strings.TrimSpace(stderr.String()), "auth token: ")
+	require.True(t, ok, stderr.String())
+	t.Setenv("SHORTENER_TOKEN", token)
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run(context.Background(), []string{"-server", ts.URL, "-output", "json", "delete", "-batch-size", "2"},
+		strings.NewReader(ids), &stdout, &stderr)
+	assert.Equal(t, exitOK, code, stderr.String())
+	assert.JSONEq(t, `{"accepted": ["a", "b", "c", "d", "e"]}`, stdout.String())
+
+	// пачка больше допустимой отвергается сервером
+	stdout.Reset()
+	stderr.Reset()
+	code = run(context.Background(), []string{"-server", ts.URL, "delete", "-batch-size", "3"},
+		strings.NewReader(ids), &stdout, &stderr)
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "IDs 1-3")
+
+	code = run(context.Background(), []string{"-server", ts.URL, "delete", "-batch-size", "0", "a"},
+		strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, exitUsage, code)
+}
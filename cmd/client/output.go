@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	outputPlain = "plain"
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeTable печатает строки, выровненные по столбцам. Заголовок
+// выводится только в формате table, в формате plain столбцы разделены
+// табуляцией, чтобы вывод было удобно разбирать в скриптах.
+func (app *cli) writeTable(header []string, rows [][]string) error {
+	if app.output == outputPlain {
+		for _, row := range rows {
+			if _, err := fmt.Fprintln(app.stdout, strings.Join(row, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(app.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
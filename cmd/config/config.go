@@ -3,29 +3,128 @@ package config
 import (
 	"flag"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/analytics"
+	"github.com/ma-shulgin/go-link-shortener/internal/app"
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
 )
 
 type Config struct {
-	ServerAddress   string
-	BaseURL         string
-	LogLevel        string
-	FileStoragePath string
-	DatabaseDSN     string
+	ServerAddress    string
+	GRPCAddress      string
+	BaseURL          string
+	LogLevel         string
+	FileStoragePath  string
+	BoltStoragePath  string
+	DatabaseDSN      string
+	AuthSecret       string
+	AdminToken       string
+	PurgeInterval    time.Duration
+	AliasMinLength   int
+	AliasMaxLength   int
+	AliasCharset     string
+	AliasReserved    []string
+	IDGenerator      string
+	IDLength         int
+	StatsFilePath    string
+	StatsSalt        string
+	ClickPipeline    analytics.PipelineConfig
+	ShutdownTimeout  time.Duration
+	FileSync         string
+	FileSyncInterval time.Duration
+	Cache            storage.CacheConfig
+	URLSchemes       []string
+	URLStripFragment bool
+	URLStripPort     bool
+	URLMaxLength     int
+	DomainAllowFile  string
+	DomainDenyFile   string
+	DomainReload     time.Duration
+	RateLimit        app.RateLimitConfig
+	BodyLimits       app.BodyLimits
 }
 
 func GetConfig() *Config {
-	var serverAddress, baseURL, logLevel, fileStoragePath, databaseDSN string
+	var serverAddress, baseURL, logLevel, fileStoragePath, databaseDSN, authSecret string
 
 	flag.StringVar(&serverAddress, "a", "localhost:8080", "HTTP server startup address")
+	var grpcAddress string
+	flag.StringVar(&grpcAddress, "grpc-address", "", "gRPC server address; empty disables the gRPC server")
 	flag.StringVar(&baseURL, "b", "http://localhost:8080", "Base address for shortened URLs")
 	flag.StringVar(&logLevel, "l", "info", "log level")
 	flag.StringVar(&fileStoragePath, "f", "", "File storage path")
 	flag.StringVar(&databaseDSN, "d", "", "Database connection string")
+	var boltStoragePath string
+	flag.StringVar(&boltStoragePath, "bolt-file", "", "Embedded bolt database path")
+	flag.StringVar(&authSecret, "k", "", "Secret key for signing auth tokens")
+	var adminToken string
+	flag.StringVar(&adminToken, "admin-token", "", "Token for administrative export and import via the X-Admin-Token header")
+	var purgeInterval time.Duration
+	flag.DurationVar(&purgeInterval, "purge-interval", time.Minute, "Interval between purges of expired URLs; 0 disables purging")
+	var aliasMinLength, aliasMaxLength int
+	var aliasCharset, aliasReserved string
+	flag.IntVar(&aliasMinLength, "alias-min-length", 3, "Minimum length of custom aliases")
+	flag.IntVar(&aliasMaxLength, "alias-max-length", 32, "Maximum length of custom aliases")
+	flag.StringVar(&aliasCharset, "alias-charset", "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_", "Characters allowed in custom aliases")
+	flag.StringVar(&aliasReserved, "alias-reserved", "", "Comma-separated list of additional reserved aliases")
+	var idGenerator string
+	var idLength int
+	flag.StringVar(&idGenerator, "id-generator", "hash", "Short ID generation strategy: hash, random or counter")
+	flag.IntVar(&idLength, "id-length", 8, "Length of random short IDs")
+	var statsFilePath, statsSalt string
+	flag.StringVar(&statsFilePath, "stats-file", "", "Click statistics file path (defaults to the file storage path with .clicks suffix)")
+	flag.StringVar(&statsSalt, "stats-salt", "", "Salt for hashing client IP addresses in click statistics")
+	clickPipeline := analytics.DefaultPipelineConfig()
+	flag.IntVar(&clickPipeline.QueueSize, "click-queue-size", clickPipeline.QueueSize, "Capacity of the click event queue")
+	flag.IntVar(&clickPipeline.Workers, "click-workers", clickPipeline.Workers, "Number of click event writers")
+	flag.IntVar(&clickPipeline.BatchSize, "click-batch-size", clickPipeline.BatchSize, "Maximum number of click events written at once")
+	flag.DurationVar(&clickPipeline.FlushInterval, "click-flush-interval", clickPipeline.FlushInterval, "Maximum delay before click events are written")
+	flag.StringVar(&clickPipeline.Overflow, "click-overflow", clickPipeline.Overflow, "What to do with click events when the queue is full: drop or block")
+	var shutdownTimeout time.Duration
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 10*time.Second, "Time to wait for in-flight requests on shutdown")
+	var fileSync string
+	var fileSyncInterval time.Duration
+	flag.StringVar(&fileSync, "file-sync", "interval", "When to fsync the storage file: always, interval or never")
+	flag.DurationVar(&fileSyncInterval, "file-sync-interval", time.Second, "Interval between fsyncs of the storage file with -file-sync=interval")
+	var cache storage.CacheConfig
+	flag.IntVar(&cache.Size, "cache-size", 0, "Number of redirects cached in memory; 0 disables the cache")
+	flag.DurationVar(&cache.TTL, "cache-ttl", time.Minute, "How long cached redirects stay valid; 0 keeps them until evicted")
+	flag.DurationVar(&cache.NegativeTTL, "cache-negative-ttl", 0, "How long unknown short URLs stay cached; 0 disables negative caching")
+	var urlSchemes string
+	var urlStripFragment, urlStripPort bool
+	var urlMaxLength int
+	flag.StringVar(&urlSchemes, "url-schemes", "http,https", "Comma-separated list of URL schemes allowed for shortening")
+	flag.BoolVar(&urlStripFragment, "url-strip-fragment", true, "Remove #fragment from URLs before shortening")
+	flag.BoolVar(&urlStripPort, "url-strip-default-port", true, "Remove the scheme's default port from URLs before shortening")
+	flag.IntVar(&urlMaxLength, "url-max-length", 2048, "Maximum length of URLs accepted for shortening; 0 disables the limit")
+	var domainAllowFile, domainDenyFile string
+	var domainReload time.Duration
+	flag.StringVar(&domainAllowFile, "domain-allow-file", "", "File with domains allowed for shortening; other domains are rejected")
+	flag.StringVar(&domainDenyFile, "domain-deny-file", "", "File with domains blocked for shortening and redirects")
+	flag.DurationVar(&domainReload, "domain-reload-interval", 5*time.Second, "How often domain list files are checked for changes; 0 disables reloading")
+	var rateLimit app.RateLimitConfig
+	var trustedProxies string
+	flag.Float64Var(&rateLimit.Create.Rate, "rate-create", 10, "Links a client may create per second on average; 0 disables the limit")
+	flag.IntVar(&rateLimit.Create.Burst, "rate-create-burst", 50, "Links a client may create in a burst")
+	flag.Float64Var(&rateLimit.Redirect.Rate, "rate-redirect", 100, "Redirects a client may follow per second on average; 0 disables the limit")
+	flag.IntVar(&rateLimit.Redirect.Burst, "rate-redirect-burst", 200, "Redirects a client may follow in a burst")
+	flag.StringVar(&trustedProxies, "trusted-proxies", "", "Comma-separated addresses or CIDRs of proxies trusted to set X-Forwarded-For")
+	flag.DurationVar(&rateLimit.IdleTimeout, "rate-limit-idle", app.DefaultRateLimitIdle, "How long an idle client's rate limit state is kept")
+	bodyLimits := app.DefaultBodyLimits()
+	flag.Int64Var(&bodyLimits.MaxBodyBytes, "max-body-size", bodyLimits.MaxBodyBytes, "Maximum request body size in bytes as sent by the client; 0 disables the limit")
+	flag.Int64Var(&bodyLimits.MaxDecompressedBytes, "max-decompressed-size", bodyLimits.MaxDecompressedBytes, "Maximum size of a gzip request body after decompression; 0 disables the limit")
+	flag.IntVar(&bodyLimits.MaxBatchItems, "max-batch-items", bodyLimits.MaxBatchItems, "Maximum number of URLs in a batch request; 0 disables the limit")
 	flag.Parse()
 
 	if envServerAddress := os.Getenv("SERVER_ADDRESS"); envServerAddress != "" {
 		serverAddress = envServerAddress
 	}
+	if envGRPCAddress := os.Getenv("GRPC_ADDRESS"); envGRPCAddress != "" {
+		grpcAddress = envGRPCAddress
+	}
 	if envBaseURL := os.Getenv("BASE_URL"); envBaseURL != "" {
 		baseURL = envBaseURL
 	}
@@ -38,12 +137,166 @@ func GetConfig() *Config {
 	if envDatabaseDSN := os.Getenv("DATABASE_DSN"); envDatabaseDSN != "" {
 		databaseDSN = envDatabaseDSN
 	}
+	if envBoltStoragePath := os.Getenv("BOLT_STORAGE_PATH"); envBoltStoragePath != "" {
+		boltStoragePath = envBoltStoragePath
+	}
+	if envAuthSecret := os.Getenv("AUTH_SECRET"); envAuthSecret != "" {
+		authSecret = envAuthSecret
+	}
+	if envAdminToken := os.Getenv("ADMIN_TOKEN"); envAdminToken != "" {
+		adminToken = envAdminToken
+	}
+	durationFromEnv("PURGE_INTERVAL", &purgeInterval)
+	intFromEnv("ALIAS_MIN_LENGTH", &aliasMinLength)
+	intFromEnv("ALIAS_MAX_LENGTH", &aliasMaxLength)
+	if envAliasCharset := os.Getenv("ALIAS_CHARSET"); envAliasCharset != "" {
+		aliasCharset = envAliasCharset
+	}
+	if envAliasReserved := os.Getenv("ALIAS_RESERVED"); envAliasReserved != "" {
+		aliasReserved = envAliasReserved
+	}
+	if envIDGenerator := os.Getenv("ID_GENERATOR"); envIDGenerator != "" {
+		idGenerator = envIDGenerator
+	}
+	intFromEnv("ID_LENGTH", &idLength)
+	if envStatsFilePath := os.Getenv("STATS_FILE_PATH"); envStatsFilePath != "" {
+		statsFilePath = envStatsFilePath
+	}
+	if envStatsSalt := os.Getenv("STATS_SALT"); envStatsSalt != "" {
+		statsSalt = envStatsSalt
+	}
+	intFromEnv("CLICK_QUEUE_SIZE", &clickPipeline.QueueSize)
+	intFromEnv("CLICK_WORKERS", &clickPipeline.Workers)
+	intFromEnv("CLICK_BATCH_SIZE", &clickPipeline.BatchSize)
+	durationFromEnv("CLICK_FLUSH_INTERVAL", &clickPipeline.FlushInterval)
+	if envClickOverflow := os.Getenv("CLICK_OVERFLOW"); envClickOverflow != "" {
+		clickPipeline.Overflow = envClickOverflow
+	}
+	durationFromEnv("SHUTDOWN_TIMEOUT", &shutdownTimeout)
+	if envFileSync := os.Getenv("FILE_SYNC"); envFileSync != "" {
+		fileSync = envFileSync
+	}
+	durationFromEnv("FILE_SYNC_INTERVAL", &fileSyncInterval)
+	intFromEnv("CACHE_SIZE", &cache.Size)
+	durationFromEnv("CACHE_TTL", &cache.TTL)
+	durationFromEnv("CACHE_NEGATIVE_TTL", &cache.NegativeTTL)
+	if envURLSchemes := os.Getenv("URL_SCHEMES"); envURLSchemes != "" {
+		urlSchemes = envURLSchemes
+	}
+	boolFromEnv("URL_STRIP_FRAGMENT", &urlStripFragment)
+	boolFromEnv("URL_STRIP_DEFAULT_PORT", &urlStripPort)
+	intFromEnv("URL_MAX_LENGTH", &urlMaxLength)
+	if envDomainAllowFile := os.Getenv("DOMAIN_ALLOW_FILE"); envDomainAllowFile != "" {
+		domainAllowFile = envDomainAllowFile
+	}
+	if envDomainDenyFile := os.Getenv("DOMAIN_DENY_FILE"); envDomainDenyFile != "" {
+		domainDenyFile = envDomainDenyFile
+	}
+	durationFromEnv("DOMAIN_RELOAD_INTERVAL", &domainReload)
+	floatFromEnv("RATE_CREATE", &rateLimit.Create.Rate)
+	intFromEnv("RATE_CREATE_BURST", &rateLimit.Create.Burst)
+	floatFromEnv("RATE_REDIRECT", &rateLimit.Redirect.Rate)
+	intFromEnv("RATE_REDIRECT_BURST", &rateLimit.Redirect.Burst)
+	if envTrustedProxies := os.Getenv("TRUSTED_PROXIES"); envTrustedProxies != "" {
+		trustedProxies = envTrustedProxies
+	}
+	rateLimit.TrustedProxies = splitList(trustedProxies)
+	durationFromEnv("RATE_LIMIT_IDLE", &rateLimit.IdleTimeout)
+	int64FromEnv("MAX_BODY_SIZE", &bodyLimits.MaxBodyBytes)
+	int64FromEnv("MAX_DECOMPRESSED_SIZE", &bodyLimits.MaxDecompressedBytes)
+	intFromEnv("MAX_BATCH_ITEMS", &bodyLimits.MaxBatchItems)
+	if statsFilePath == "" && boltStoragePath != "" {
+		statsFilePath = boltStoragePath + ".clicks"
+	}
+	if statsFilePath == "" && fileStoragePath != "" {
+		statsFilePath = fileStoragePath + ".clicks"
+	}
 
 	return &Config{
-		ServerAddress:   serverAddress,
-		BaseURL:         baseURL,
-		LogLevel:        logLevel,
-		FileStoragePath: fileStoragePath,
-		DatabaseDSN:     databaseDSN,
+		ServerAddress:    serverAddress,
+		GRPCAddress:      grpcAddress,
+		BaseURL:          baseURL,
+		LogLevel:         logLevel,
+		FileStoragePath:  fileStoragePath,
+		BoltStoragePath:  boltStoragePath,
+		DatabaseDSN:      databaseDSN,
+		AuthSecret:       authSecret,
+		AdminToken:       adminToken,
+		PurgeInterval:    purgeInterval,
+		AliasMinLength:   aliasMinLength,
+		AliasMaxLength:   aliasMaxLength,
+		AliasCharset:     aliasCharset,
+		AliasReserved:    splitList(aliasReserved),
+		IDGenerator:      idGenerator,
+		IDLength:         idLength,
+		StatsFilePath:    statsFilePath,
+		StatsSalt:        statsSalt,
+		ClickPipeline:    clickPipeline,
+		ShutdownTimeout:  shutdownTimeout,
+		FileSync:         fileSync,
+		FileSyncInterval: fileSyncInterval,
+		Cache:            cache,
+		URLSchemes:       splitList(urlSchemes),
+		URLStripFragment: urlStripFragment,
+		URLStripPort:     urlStripPort,
+		URLMaxLength:     urlMaxLength,
+		DomainAllowFile:  domainAllowFile,
+		DomainDenyFile:   domainDenyFile,
+		DomainReload:     domainReload,
+		RateLimit:        rateLimit,
+		BodyLimits:       bodyLimits,
+	}
+}
+
+// Значения переменных окружения, которые не удалось разобрать, игнорируются:
+// остаётся значение из флага или значение по умолчанию.
+
+func durationFromEnv(name string, dst *time.Duration) {
+	if env := os.Getenv(name); env != "" {
+		if d, err := time.ParseDuration(env); err == nil {
+			*dst = d
+		}
+	}
+}
+
+func intFromEnv(name string, dst *int) {
+	if env := os.Getenv(name); env != "" {
+		if n, err := strconv.Atoi(env); err == nil {
+			*dst = n
+		}
+	}
+}
+
+func int64FromEnv(name string, dst *int64) {
+	if env := os.Getenv(name); env != "" {
+		if n, err := strconv.ParseInt(env, 10, 64); err == nil {
+			*dst = n
+		}
+	}
+}
+
+func floatFromEnv(name string, dst *float64) {
+	if env := os.Getenv(name); env != "" {
+		if f, err := strconv.ParseFloat(env, 64); err == nil {
+			*dst = f
+		}
+	}
+}
+
+func boolFromEnv(name string, dst *bool) {
+	if env := os.Getenv(name); env != "" {
+		if b, err := strconv.ParseBool(env); err == nil {
+			*dst = b
+		}
+	}
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -1,15 +1,35 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/ma-shulgin/go-link-shortener/cmd/config"
+	"github.com/ma-shulgin/go-link-shortener/internal/analytics"
 	"github.com/ma-shulgin/go-link-shortener/internal/app"
+	"github.com/ma-shulgin/go-link-shortener/internal/auth"
 	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"github.com/ma-shulgin/go-link-shortener/internal/metrics"
 	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 func main() {
+	migrate, args, err := parseMigrateCommand(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	os.Args = append(os.Args[:1], args...)
+
 	cfg := config.GetConfig()
 
 	if err := logger.Initialize(cfg.LogLevel); err != nil {
@@ -18,24 +38,225 @@ func main() {
 
 	logger.Log.Debugln("Parsed config:", cfg)
 
+	if migrate != nil {
+		err = runMigrate(cfg, migrate)
+	} else {
+		err = run(cfg)
+	}
+	if err != nil {
+		logger.Log.Error(err)
+	}
+	logger.Log.Sync()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// run запускает сервер и блокируется до получения сигнала остановки.
+// Отложенные вызовы закрывают компоненты в порядке, обратном запуску:
+// сначала фоновые обработчики дописывают очереди, затем закрываются хранилища.
+func run(cfg *config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
+
 	var urlStore storage.URLStore
+	var backend string
 	var err error
 	if cfg.DatabaseDSN != "" {
+		backend = "postgres"
 		urlStore, err = storage.InitPostgresStore(cfg.DatabaseDSN)
+	} else if cfg.BoltStoragePath != "" {
+		backend = "bolt"
+		urlStore, err = storage.InitBoltStore(cfg.BoltStoragePath)
 	} else if cfg.FileStoragePath != "" {
-		urlStore, err = storage.InitFileStore(cfg.FileStoragePath)
+		backend = "file"
+		var policy storage.SyncPolicy
+		policy, err = storage.ParseSyncPolicy(cfg.FileSync)
+		if err != nil {
+			return err
+		}
+		urlStore, err = storage.InitFileStore(cfg.FileStoragePath, storage.WithSyncPolicy(policy, cfg.FileSyncInterval))
 	} else {
+		backend = "memory"
 		urlStore = storage.InitMemoryStore()
 	}
 
 	if err != nil {
-		logger.Log.Fatal(err)
+		return err
+	}
+	urlStore = storage.Instrument(urlStore, backend)
+	if cfg.Cache.Size > 0 {
+		cache := storage.NewCachedStore(urlStore, cfg.Cache)
+		urlStore = cache
+		metrics.RegisterCounterFunc("shortener_cache_hits_total", "Number of redirects served from the cache.", func() float64 {
+			return float64(cache.Stats().Hits)
+		})
+		metrics.RegisterCounterFunc("shortener_cache_misses_total", "Number of redirects looked up in the storage.", func() float64 {
+			return float64(cache.Stats().Misses)
+		})
+		metrics.RegisterCounterFunc("shortener_cache_evictions_total", "Number of entries evicted from the cache.", func() float64 {
+			return float64(cache.Stats().Evictions)
+		})
 	}
-	defer urlStore.Close()
+	defer func() {
+		if err := urlStore.Close(); err != nil {
+			logger.Log.Error("Failed to close URL storage", zap.Error(err))
+		}
+	}()
+
+	metrics.RegisterGaugeFunc("shortener_stored_links", "Number of links in the URL storage.", func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		n, err := urlStore.CountURLs(ctx)
+		if err != nil {
+			logger.Log.Error("Failed to count stored links", zap.Error(err))
+			return math.NaN()
+		}
+		return float64(n)
+	})
 
-	logger.Log.Infow("Starting server", "address", cfg.ServerAddress)
-	err = http.ListenAndServe(cfg.ServerAddress, app.RootRouter(urlStore, cfg.BaseURL))
+	var clickStore analytics.Store
+	if cfg.DatabaseDSN != "" {
+		clickStore, err = analytics.InitPostgresStore(cfg.DatabaseDSN)
+	} else if cfg.StatsFilePath != "" {
+		clickStore, err = analytics.InitFileStore(cfg.StatsFilePath)
+	} else {
+		clickStore = analytics.InitMemoryStore()
+	}
 	if err != nil {
-		logger.Log.Fatal(err)
+		return err
+	}
+	defer func() {
+		if err := clickStore.Close(); err != nil {
+			logger.Log.Error("Failed to close click storage", zap.Error(err))
+		}
+	}()
+
+	clickPipeline, err := analytics.NewPipeline(clickStore, cfg.ClickPipeline)
+	if err != nil {
+		return err
+	}
+	defer clickPipeline.Close()
+
+	metrics.RegisterCounterFunc("shortener_clicks_dropped_total", "Number of click events dropped by the pipeline.", func() float64 {
+		return float64(clickPipeline.Stats().Dropped)
+	})
+	metrics.RegisterCounterFunc("shortener_clicks_failed_total", "Number of click events the pipeline failed to store.", func() float64 {
+		return float64(clickPipeline.Stats().Failed)
+	})
+
+	janitor := storage.NewJanitor(urlStore, cfg.PurgeInterval)
+	defer janitor.Close()
+
+	idGenerator, err := app.NewIDGenerator(cfg.IDGenerator, cfg.IDLength)
+	if err != nil {
+		return err
+	}
+
+	deleter := app.NewURLDeleter(urlStore)
+	defer deleter.Close()
+
+	opts := []app.Option{
+		app.WithDeleter(deleter),
+		app.WithIDGenerator(idGenerator),
+		app.WithAnalytics(clickStore, cfg.StatsSalt),
+		app.WithClickPipeline(clickPipeline),
+		app.WithAdminToken(cfg.AdminToken),
+		app.WithBodyLimits(cfg.BodyLimits),
+		app.WithAliasPolicy(app.AliasPolicy{
+			MinLength: cfg.AliasMinLength,
+			MaxLength: cfg.AliasMaxLength,
+			Charset:   cfg.AliasCharset,
+			Reserved:  cfg.AliasReserved,
+		}),
+		app.WithURLPolicy(app.URLPolicy{
+			AllowedSchemes:   cfg.URLSchemes,
+			StripFragment:    cfg.URLStripFragment,
+			StripDefaultPort: cfg.URLStripPort,
+			MaxLength:        cfg.URLMaxLength,
+		}),
+	}
+	// HTTP и gRPC должны принимать токены друг друга
+	signer := auth.NewSigner(cfg.AuthSecret)
+	if cfg.AuthSecret == "" {
+		if signer, err = auth.NewRandomSigner(); err != nil {
+			return err
+		}
+	}
+	opts = append(opts, app.WithSigner(signer))
+	limiter, err := app.NewRateLimiter(cfg.RateLimit)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, app.WithRateLimiter(limiter))
+	if cfg.DomainAllowFile != "" || cfg.DomainDenyFile != "" {
+		domains, err := app.NewDomainPolicy(cfg.DomainAllowFile, cfg.DomainDenyFile, cfg.DomainReload)
+		if err != nil {
+			return err
+		}
+		defer domains.Close()
+		opts = append(opts, app.WithDomainPolicy(domains))
+	}
+
+	server := &http.Server{
+		Addr:    cfg.ServerAddress,
+		Handler: app.RootRouter(urlStore, cfg.BaseURL, opts...),
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		logger.Log.Infow("Starting server", "address", cfg.ServerAddress)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	var grpcServer *grpc.Server
+	if cfg.GRPCAddress != "" {
+		listener, err := net.Listen("tcp", cfg.GRPCAddress)
+		if err != nil {
+			server.Close()
+			return err
+		}
+		grpcServer = app.NewGRPCServer(urlStore, cfg.BaseURL, opts...)
+		go func() {
+			logger.Log.Infow("Starting gRPC server", "address", cfg.GRPCAddress)
+			serveErr <- grpcServer.Serve(listener)
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		server.Close()
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	logger.Log.Infow("Shutting down server", "timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	// gRPC-сервер останавливается параллельно с HTTP и в пределах того же таймаута
+	grpcStopped := make(chan struct{})
+	if grpcServer != nil {
+		go func() {
+			grpcServer.GracefulStop()
+			close(grpcStopped)
+		}()
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Log.Error("Failed to drain connections", zap.Error(err))
+		server.Close()
+	}
+	if grpcServer != nil {
+		select {
+		case <-grpcStopped:
+		case <-shutdownCtx.Done():
+			logger.Log.Error("Failed to drain gRPC connections", zap.Error(shutdownCtx.Err()))
+			grpcServer.Stop()
+		}
 	}
+	logger.Log.Info("Server stopped, flushing background workers")
+	return nil
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/cmd/config"
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+)
+
+const migrateUsage = "usage: shortener migrate up|down [N]|status [flags]"
+
+type migrateCommand struct {
+	action string
+	steps  int
+}
+
+// parseMigrateCommand распознаёт подкоманду "migrate up|down [N]|status"
+// в начале аргументов и возвращает оставшиеся аргументы для разбора флагов.
+func parseMigrateCommand(args []string) (*migrateCommand, []string, error) {
+	if len(args) == 0 || args[0] != "migrate" {
+		return nil, args, nil
+	}
+	if len(args) < 2 {
+		return nil, nil, errors.New(migrateUsage)
+	}
+
+	cmd := &migrateCommand{action: args[1], steps: 1}
+	rest := args[2:]
+	switch cmd.action {
+	case "up", "status":
+	case "down":
+		if len(rest) > 0 {
+			if steps, err := strconv.Atoi(rest[0]); err == nil {
+				if steps <= 0 {
+					return nil, nil, fmt.Errorf("invalid number of steps: %d", steps)
+				}
+				cmd.steps = steps
+				rest = rest[1:]
+			}
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown migrate action %q; %s", cmd.action, migrateUsage)
+	}
+	return cmd, rest, nil
+}
+
+func runMigrate(cfg *config.Config, cmd *migrateCommand) error {
+	if cfg.DatabaseDSN == "" {
+		return errors.New("migrate requires a database connection string (-d or DATABASE_DSN)")
+	}
+
+	db, err := sql.Open("pgx", cfg.DatabaseDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := storage.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch cmd.action {
+	case "up":
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Log.Infow("Migrations applied", "count", n)
+	case "down":
+		n, err := migrator.Down(ctx, cmd.steps)
+		if err != nil {
+			return err
+		}
+		logger.Log.Infow("Migrations reverted", "count", n)
+	case "status":
+		states, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, state := range states {
+			appliedAt := "pending"
+			if state.AppliedAt != nil {
+				appliedAt = state.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", state.Version, state.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+	return nil
+}
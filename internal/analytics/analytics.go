@@ -0,0 +1,159 @@
+package analytics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+const dayLayout = "2006-01-02"
+
+// Click — один переход по короткой ссылке.
+type Click struct {
+	ShortURL  string    `json:"short_url"`
+	Timestamp time.Time `json:"timestamp"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	IPHash    string    `json:"ip_hash,omitempty"`
+}
+
+type DayClicks struct {
+	Date   string `json:"date"`
+	Clicks int    `json:"clicks"`
+}
+
+type ReferrerClicks struct {
+	Referrer string `json:"referrer"`
+	Clicks   int    `json:"clicks"`
+}
+
+// Stats — агрегированная статистика переходов по одной ссылке.
+type Stats struct {
+	TotalClicks    int              `json:"total_clicks"`
+	UniqueVisitors int              `json:"unique_visitors"`
+	ClicksPerDay   []DayClicks      `json:"clicks_per_day"`
+	TopReferrers   []ReferrerClicks `json:"top_referrers"`
+}
+
+type Store interface {
+	AddClicks(ctx context.Context, clicks []Click) error
+	// Stats возвращает статистику по ссылке и не более topReferrers источников.
+	Stats(ctx context.Context, shortURL string, topReferrers int) (Stats, error)
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// HashIP скрывает адрес клиента, сохраняя возможность считать уникальных посетителей.
+func HashIP(ip, salt string) string {
+	sum := sha256.Sum256([]byte(salt + ip))
+	return hex.EncodeToString(sum[:])
+}
+
+// linkStats — накопленная статистика одной ссылки. Сами переходы не
+// хранятся: память растёт с числом дней, источников и уникальных
+// посетителей ссылки, а не с числом переходов.
+type linkStats struct {
+	total     int
+	days      map[string]int
+	referrers map[string]int
+	// visitors хранит 64-битные отпечатки хешей адресов вместо самих хешей
+	visitors map[uint64]struct{}
+}
+
+func newLinkStats() *linkStats {
+	return &linkStats{
+		days:      make(map[string]int),
+		referrers: make(map[string]int),
+		visitors:  make(map[uint64]struct{}),
+	}
+}
+
+func visitorKey(ipHash string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(ipHash))
+	return h.Sum64()
+}
+
+func (s *linkStats) add(click Click) {
+	s.total++
+	s.days[click.Timestamp.UTC().Format(dayLayout)]++
+	if click.Referrer != "" {
+		s.referrers[click.Referrer]++
+	}
+	s.visitors[visitorKey(click.IPHash)] = struct{}{}
+}
+
+// linkRecord — сохраняемый вид linkStats.
+type linkRecord struct {
+	ShortURL  string         `json:"short_url"`
+	Total     int            `json:"total"`
+	Days      map[string]int `json:"days"`
+	Referrers map[string]int `json:"referrers,omitempty"`
+	Visitors  []uint64       `json:"visitors"`
+}
+
+func (s *linkStats) record(shortURL string) linkRecord {
+	record := linkRecord{
+		ShortURL:  shortURL,
+		Total:     s.total,
+		Days:      s.days,
+		Referrers: s.referrers,
+		Visitors:  make([]uint64, 0, len(s.visitors)),
+	}
+	for v := range s.visitors {
+		record.Visitors = append(record.Visitors, v)
+	}
+	sort.Slice(record.Visitors, func(i, j int) bool { return record.Visitors[i] < record.Visitors[j] })
+	return record
+}
+
+func (s *linkStats) merge(record linkRecord) {
+	s.total += record.Total
+	for day, n := range record.Days {
+		s.days[day] += n
+	}
+	for referrer, n := range record.Referrers {
+		s.referrers[referrer] += n
+	}
+	for _, v := range record.Visitors {
+		s.visitors[v] = struct{}{}
+	}
+}
+
+// stats собирает статистику для ответа. Нулевой указатель — ссылка без переходов.
+func (s *linkStats) stats(topReferrers int) Stats {
+	stats := Stats{
+		ClicksPerDay: []DayClicks{},
+		TopReferrers: []ReferrerClicks{},
+	}
+	if s == nil {
+		return stats
+	}
+	stats.TotalClicks = s.total
+	stats.UniqueVisitors = len(s.visitors)
+
+	for day, n := range s.days {
+		stats.ClicksPerDay = append(stats.ClicksPerDay, DayClicks{Date: day, Clicks: n})
+	}
+	sort.Slice(stats.ClicksPerDay, func(i, j int) bool {
+		return stats.ClicksPerDay[i].Date < stats.ClicksPerDay[j].Date
+	})
+
+	for referrer, n := range s.referrers {
+		stats.TopReferrers = append(stats.TopReferrers, ReferrerClicks{Referrer: referrer, Clicks: n})
+	}
+	sort.Slice(stats.TopReferrers, func(i, j int) bool {
+		a, b := stats.TopReferrers[i], stats.TopReferrers[j]
+		if a.Clicks != b.Clicks {
+			return a.Clicks > b.Clicks
+		}
+		return a.Referrer < b.Referrer
+	})
+	if len(stats.TopReferrers) > topReferrers {
+		stats.TopReferrers = stats.TopReferrers[:topReferrers]
+	}
+	return stats
+}
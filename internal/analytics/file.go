@@ -0,0 +1,203 @@
+package analytics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+// compactClicks — число переходов в журнале, после которого он сжимается.
+const compactClicks = 100_000
+
+// FileStore дописывает переходы в файл в формате JSON Lines и держит в
+// памяти только счётчики. Когда переходов в журнале набирается
+// compactAfter, журнал заменяется счётчиками по ссылкам, чтобы файл и время
+// запуска не росли вместе с историей.
+type FileStore struct {
+	mu    sync.RWMutex
+	path  string
+	file  *os.File
+	links map[string]*linkStats
+	// clicks — число строк-переходов в журнале после последнего сжатия
+	clicks       int
+	compactAfter int
+}
+
+// logLine — строка журнала: переход или, после сжатия, счётчики ссылки.
+type logLine struct {
+	Click
+	Link *linkRecord `json:"link,omitempty"`
+}
+
+func InitFileStore(filePath string) (*FileStore, error) {
+	store := &FileStore{
+		path:         filePath,
+		links:        make(map[string]*linkStats),
+		compactAfter: compactClicks,
+	}
+
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	store.file = file
+	if err := store.load(filePath); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// load читает журнал переходов. Повреждённые строки внутри файла
+// пропускаются, а оборванная последняя запись отрезается, чтобы следующие
+// записи начинались с новой строки.
+func (s *FileStore) load(path string) error {
+	reader := bufio.NewReader(s.file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if len(line) == 0 {
+			return nil
+		}
+
+		complete := line[len(line)-1] == '\n'
+		var entry logLine
+		decodeErr := json.Unmarshal(bytes.TrimSuffix(line, []byte{'\n'}), &entry)
+		if !complete {
+			if decodeErr != nil {
+				logger.Log.Warnw("Truncating torn click at the end of the stats file",
+					"path", path, "offset", offset, zap.Error(decodeErr))
+				return s.file.Truncate(offset)
+			}
+			if _, err := s.file.Write([]byte{'\n'}); err != nil {
+				return err
+			}
+		}
+
+		if decodeErr != nil {
+			logger.Log.Warnw("Skipping corrupted click in the stats file",
+				"path", path, "offset", offset, zap.Error(decodeErr))
+		} else {
+			s.apply(entry)
+		}
+		offset += int64(len(line))
+	}
+}
+
+func (s *FileStore) apply(entry logLine) {
+	if entry.Link == nil {
+		addClicks(s.links, []Click{entry.Click})
+		s.clicks++
+		return
+	}
+	link, ok := s.links[entry.Link.ShortURL]
+	if !ok {
+		link = newLinkStats()
+		s.links[entry.Link.ShortURL] = link
+	}
+	link.merge(*entry.Link)
+}
+
+func (s *FileStore) AddClicks(ctx context.Context, clicks []Click) error {
+	var buf []byte
+	for _, click := range clicks {
+		data, err := json.Marshal(click)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(buf); err != nil {
+		return err
+	}
+	addClicks(s.links, clicks)
+	s.clicks += len(clicks)
+	if s.compactAfter > 0 && s.clicks >= s.compactAfter {
+		// переходы уже записаны, поэтому ошибка сжатия их не теряет
+		if err := s.compact(); err != nil {
+			logger.Log.Warn("Failed to compact the stats file", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// compact записывает счётчики во временный файл и атомарно подменяет им
+// журнал. Вызывается под блокировкой записи.
+func (s *FileStore) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for shortURL, link := range s.links {
+		record := link.record(shortURL)
+		if err := enc.Encode(struct {
+			Link *linkRecord `json:"link"`
+		}{&record}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = file
+	s.clicks = 0
+	logger.Log.Infow("Compacted the stats file", "path", s.path, "links", len(s.links))
+	return nil
+}
+
+func (s *FileStore) Stats(ctx context.Context, shortURL string, topReferrers int) (Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.links[shortURL].stats(topReferrers), nil
+}
+
+func (s *FileStore) Ping(ctx context.Context) error {
+	_, err := s.file.Stat()
+	return err
+}
+
+func (s *FileStore) Close() error {
+	if s.file != nil {
+		return s.file.Close()
+	}
+	return nil
+}
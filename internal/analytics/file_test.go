@@ -0,0 +1,93 @@
+package analytics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStoreRecovery(t *testing.T) {
+	ctx := context.Background()
+	first := `{"short_url":"abc","timestamp":"2024-01-01T00:00:00Z"}` + "\n"
+	corrupted := `{"short_url":"abc","timest` + "\n"
+	second := `{"short_url":"abc","timestamp":"2024-01-02T00:00:00Z"}` + "\n"
+	torn := `{"short_url":"abc","time`
+
+	path := filepath.Join(t.TempDir(), "clicks.json")
+	require.NoError(t, os.WriteFile(path, []byte(first+corrupted+second+torn), 0644))
+
+	store, err := InitFileStore(path)
+	require.NoError(t, err)
+	stats, err := store.Stats(ctx, "abc", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TotalClicks)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, first+corrupted+second, string(data), "torn tail must be truncated")
+
+	require.NoError(t, store.AddClicks(ctx, []Click{{ShortURL: "abc", Timestamp: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)}}))
+	require.NoError(t, store.Close())
+
+	reopened, err := InitFileStore(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	stats, err = reopened.Stats(ctx, "abc", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.TotalClicks)
+}
+
+func TestFileStoreCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "clicks.json")
+	store, err := InitFileStore(path)
+	require.NoError(t, err)
+	store.compactAfter = 3
+
+	day := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clicks := []Click{
+		{ShortURL: "abc", Timestamp: day, Referrer: "https://a.example", IPHash: "one"},
+		{ShortURL: "abc", Timestamp: day, Referrer: "https://a.example", IPHash: "two"},
+		{ShortURL: "xyz", Timestamp: day, IPHash: "one"},
+	}
+	require.NoError(t, store.AddClicks(ctx, clicks))
+	expected, err := store.Stats(ctx, "abc", 10)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{
+		TotalClicks:    2,
+		UniqueVisitors: 2,
+		ClicksPerDay:   []DayClicks{{Date: "2024-01-01", Clicks: 2}},
+		TopReferrers:   []ReferrerClicks{{Referrer: "https://a.example", Clicks: 2}},
+	}, expected)
+
+	// журнал заменён счётчиками: по строке на ссылку
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"), string(data))
+	assert.NotContains(t, string(data), `"timestamp"`)
+
+	// после сжатия переходы снова дописываются в журнал
+	require.NoError(t, store.AddClicks(ctx, []Click{{ShortURL: "abc", Timestamp: day.Add(24 * time.Hour), IPHash: "one"}}))
+	require.NoError(t, store.Close())
+
+	reopened, err := InitFileStore(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	stats, err := reopened.Stats(ctx, "abc", 10)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{
+		TotalClicks:    3,
+		UniqueVisitors: 2,
+		ClicksPerDay:   []DayClicks{{Date: "2024-01-01", Clicks: 2}, {Date: "2024-01-02", Clicks: 1}},
+		TopReferrers:   []ReferrerClicks{{Referrer: "https://a.example", Clicks: 2}},
+	}, stats)
+	stats, err = reopened.Stats(ctx, "missing", 10)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{ClicksPerDay: []DayClicks{}, TopReferrers: []ReferrerClicks{}}, stats)
+}
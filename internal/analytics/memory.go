@@ -0,0 +1,50 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore хранит только счётчики переходов, а не сами переходы.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	links map[string]*linkStats
+}
+
+func InitMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		links: make(map[string]*linkStats),
+	}
+}
+
+func (s *MemoryStore) AddClicks(ctx context.Context, clicks []Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addClicks(s.links, clicks)
+	return nil
+}
+
+func (s *MemoryStore) Stats(ctx context.Context, shortURL string, topReferrers int) (Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.links[shortURL].stats(topReferrers), nil
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func addClicks(links map[string]*linkStats, clicks []Click) {
+	for _, click := range clicks {
+		link, ok := links[click.ShortURL]
+		if !ok {
+			link = newLinkStats()
+			links[click.ShortURL] = link
+		}
+		link.add(click)
+	}
+}
@@ -0,0 +1,170 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// OverflowDrop отбрасывает переход, если очередь заполнена.
+	OverflowDrop = "drop"
+	// OverflowBlock ждёт освобождения места в очереди, пока не отменён контекст запроса.
+	OverflowBlock = "block"
+)
+
+type PipelineConfig struct {
+	QueueSize     int
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+	Overflow      string
+}
+
+func DefaultPipelineConfig() PipelineConfig {
+	return PipelineConfig{
+		QueueSize:     10000,
+		Workers:       2,
+		BatchSize:     100,
+		FlushInterval: time.Second,
+		Overflow:      OverflowDrop,
+	}
+}
+
+// PipelineStats — счётчики событий с момента запуска конвейера.
+type PipelineStats struct {
+	Enqueued uint64
+	Dropped  uint64
+	Flushed  uint64
+	Failed   uint64
+}
+
+// Pipeline принимает переходы в ограниченную очередь, не блокируя хендлеры,
+// и пачками записывает их в Store несколькими воркерами.
+type Pipeline struct {
+	store Store
+	cfg   PipelineConfig
+	queue chan Click
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	enqueued atomic.Uint64
+	dropped  atomic.Uint64
+	flushed  atomic.Uint64
+	failed   atomic.Uint64
+}
+
+func NewPipeline(store Store, cfg PipelineConfig) (*Pipeline, error) {
+	if cfg.QueueSize <= 0 || cfg.Workers <= 0 || cfg.BatchSize <= 0 || cfg.FlushInterval <= 0 {
+		return nil, fmt.Errorf("invalid click pipeline config: %+v", cfg)
+	}
+	if cfg.Overflow != OverflowDrop && cfg.Overflow != OverflowBlock {
+		return nil, fmt.Errorf("unknown click overflow policy: %q", cfg.Overflow)
+	}
+
+	p := &Pipeline{
+		store: store,
+		cfg:   cfg,
+		queue: make(chan Click, cfg.QueueSize),
+	}
+	p.wg.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		go p.worker()
+	}
+	return p, nil
+}
+
+// Enqueue ставит переход в очередь. Возвращает false, если переход отброшен.
+func (p *Pipeline) Enqueue(ctx context.Context, click Click) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		p.dropped.Add(1)
+		return false
+	}
+
+	if p.cfg.Overflow == OverflowBlock {
+		select {
+		case p.queue <- click:
+			p.enqueued.Add(1)
+			return true
+		case <-ctx.Done():
+			p.dropped.Add(1)
+			return false
+		}
+	}
+
+	select {
+	case p.queue <- click:
+		p.enqueued.Add(1)
+		return true
+	default:
+		p.dropped.Add(1)
+		return false
+	}
+}
+
+func (p *Pipeline) Stats() PipelineStats {
+	return PipelineStats{
+		Enqueued: p.enqueued.Load(),
+		Dropped:  p.dropped.Load(),
+		Flushed:  p.flushed.Load(),
+		Failed:   p.failed.Load(),
+	}
+}
+
+// Close перестаёт принимать переходы и дожидается записи всех принятых.
+func (p *Pipeline) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *Pipeline) worker() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Click, 0, p.cfg.BatchSize)
+	for {
+		select {
+		case click, ok := <-p.queue:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= p.cfg.BatchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			p.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (p *Pipeline) flush(batch []Click) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := p.store.AddClicks(context.Background(), batch); err != nil {
+		p.failed.Add(uint64(len(batch)))
+		logger.Log.Error("Failed to save clicks", zap.Error(err), zap.Int("count", len(batch)))
+		return
+	}
+	p.flushed.Add(uint64(len(batch)))
+}
@@ -0,0 +1,74 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingStore не принимает переходы, пока не закрыт release.
+type blockingStore struct {
+	*MemoryStore
+	release chan struct{}
+}
+
+func (s *blockingStore) AddClicks(ctx context.Context, clicks []Click) error {
+	<-s.release
+	return s.MemoryStore.AddClicks(ctx, clicks)
+}
+
+func TestPipelineDropsWhenFull(t *testing.T) {
+	store := &blockingStore{MemoryStore: InitMemoryStore(), release: make(chan struct{})}
+	p, err := NewPipeline(store, PipelineConfig{
+		QueueSize:     2,
+		Workers:       1,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		Overflow:      OverflowDrop,
+	})
+	require.NoError(t, err)
+
+	accepted := 0
+	for i := 0; i < 10; i++ {
+		if p.Enqueue(context.Background(), Click{ShortURL: "abc"}) {
+			accepted++
+		}
+	}
+	// один переход может уже находиться у воркера, остальные ждут в очереди
+	assert.LessOrEqual(t, accepted, 3)
+	assert.Equal(t, uint64(10-accepted), p.Stats().Dropped)
+
+	close(store.release)
+	p.Close()
+
+	stats, err := store.Stats(context.Background(), "abc", 10)
+	require.NoError(t, err)
+	assert.Equal(t, accepted, stats.TotalClicks, "accepted clicks must be flushed on close")
+	assert.False(t, p.Enqueue(context.Background(), Click{ShortURL: "abc"}), "closed pipeline must reject clicks")
+}
+
+func TestPipelineBlocksUntilContextDone(t *testing.T) {
+	store := &blockingStore{MemoryStore: InitMemoryStore(), release: make(chan struct{})}
+	p, err := NewPipeline(store, PipelineConfig{
+		QueueSize:     1,
+		Workers:       1,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		Overflow:      OverflowBlock,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		p.Enqueue(ctx, Click{ShortURL: "abc"})
+	}
+	assert.NotZero(t, p.Stats().Dropped, "clicks must be dropped once the context is done")
+
+	close(store.release)
+	p.Close()
+	assert.Equal(t, p.Stats().Enqueued, p.Stats().Flushed)
+}
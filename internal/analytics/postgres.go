@@ -0,0 +1,103 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+)
+
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// InitPostgresStore подключается к базе со статистикой переходов. Таблицу
+// clicks создают миграции storage, их применяет storage.InitPostgresStore.
+func InitPostgresStore(dsn string) (*PostgresStore, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.Info("Analytics database initalized successfully")
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) AddClicks(ctx context.Context, clicks []Click) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO clicks (short_url, clicked_at, referrer, user_agent, ip_hash)
+		VALUES ($1, $2, $3, $4, $5)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, click := range clicks {
+		if _, err := stmt.ExecContext(ctx, click.ShortURL, click.Timestamp, click.Referrer, click.UserAgent, click.IPHash); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) Stats(ctx context.Context, shortURL string, topReferrers int) (Stats, error) {
+	stats := Stats{
+		ClicksPerDay: []DayClicks{},
+		TopReferrers: []ReferrerClicks{},
+	}
+
+	err := s.db.QueryRowContext(ctx, `SELECT count(*), count(DISTINCT ip_hash) FROM clicks WHERE short_url = $1`, shortURL).
+		Scan(&stats.TotalClicks, &stats.UniqueVisitors)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT (clicked_at AT TIME ZONE 'UTC')::date AS day, count(*)
+		FROM clicks WHERE short_url = $1 GROUP BY day ORDER BY day`, shortURL)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day time.Time
+		var n int
+		if err := rows.Scan(&day, &n); err != nil {
+			return Stats{}, err
+		}
+		stats.ClicksPerDay = append(stats.ClicksPerDay, DayClicks{Date: day.Format(dayLayout), Clicks: n})
+	}
+	if err := rows.Err(); err != nil {
+		return Stats{}, err
+	}
+
+	refRows, err := s.db.QueryContext(ctx, `SELECT referrer, count(*) AS n FROM clicks
+		WHERE short_url = $1 AND referrer <> '' GROUP BY referrer ORDER BY n DESC, referrer LIMIT $2`, shortURL, topReferrers)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer refRows.Close()
+	for refRows.Next() {
+		var rc ReferrerClicks
+		if err := refRows.Scan(&rc.Referrer, &rc.Clicks); err != nil {
+			return Stats{}, err
+		}
+		stats.TopReferrers = append(stats.TopReferrers, rc)
+	}
+	return stats, refRows.Err()
+}
+
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const DefaultAliasCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"
+
+// reservedAliases совпадают с путями, которые обслуживает RootRouter,
+// и не могут быть заняты пользователями ни при какой конфигурации.
+var reservedAliases = []string{"ping", "api", "metrics"}
+
+var ErrInvalidAlias = errors.New("invalid alias")
+
+// AliasPolicy описывает допустимые пользовательские идентификаторы ссылок.
+type AliasPolicy struct {
+	MinLength int
+	MaxLength int
+	Charset   string
+	Reserved  []string
+}
+
+func DefaultAliasPolicy() AliasPolicy {
+	return AliasPolicy{
+		MinLength: 3,
+		MaxLength: 32,
+		Charset:   DefaultAliasCharset,
+	}
+}
+
+func (p AliasPolicy) Validate(alias string) error {
+	if len(alias) < p.MinLength || len(alias) > p.MaxLength {
+		return fmt.Errorf("%w: length must be between %d and %d", ErrInvalidAlias, p.MinLength, p.MaxLength)
+	}
+	for _, c := range alias {
+		if !strings.ContainsRune(p.Charset, c) {
+			return fmt.Errorf("%w: character %q is not allowed", ErrInvalidAlias, c)
+		}
+	}
+	for _, reserved := range append(reservedAliases, p.Reserved...) {
+		if strings.EqualFold(alias, reserved) {
+			return fmt.Errorf("%w: %q is reserved", ErrInvalidAlias, alias)
+		}
+	}
+	return nil
+}
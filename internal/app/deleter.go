@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	deleteQueueSize     = 1024
+	deleteBatchSize     = 100
+	deleteFlushInterval = time.Second
+)
+
+var ErrDeleterClosed = errors.New("deleter is closed")
+
+// URLDeleter собирает запросы на удаление из всех хендлеров в один канал
+// и применяет их к хранилищу пачками — по размеру пачки или по таймеру.
+type URLDeleter struct {
+	store  storage.URLStore
+	queue  chan []storage.DeleteTask
+	done   chan struct{}
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewURLDeleter(store storage.URLStore) *URLDeleter {
+	d := &URLDeleter{
+		store: store,
+		queue: make(chan []storage.DeleteTask, deleteQueueSize),
+		done:  make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Enqueue ставит задачи в очередь и не ждёт их выполнения.
+func (d *URLDeleter) Enqueue(ctx context.Context, tasks []storage.DeleteTask) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return ErrDeleterClosed
+	}
+	select {
+	case d.queue <- tasks:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close перестаёт принимать задачи и дожидается, пока уже принятые
+// будут записаны в хранилище.
+func (d *URLDeleter) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+	<-d.done
+}
+
+func (d *URLDeleter) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(deleteFlushInterval)
+	defer ticker.Stop()
+
+	var pending []storage.DeleteTask
+	for {
+		select {
+		case tasks, ok := <-d.queue:
+			if !ok {
+				d.flush(pending)
+				return
+			}
+			pending = append(pending, tasks...)
+			if len(pending) >= deleteBatchSize {
+				d.flush(pending)
+				pending = nil
+			}
+		case <-ticker.C:
+			d.flush(pending)
+			pending = nil
+		}
+	}
+}
+
+func (d *URLDeleter) flush(tasks []storage.DeleteTask) {
+	if len(tasks) == 0 {
+		return
+	}
+	if err := d.store.DeleteURLs(context.Background(), tasks); err != nil {
+		logger.Log.Error("Failed to delete URLs", zap.Error(err))
+		return
+	}
+	logger.Log.Debugw("Deleted URLs", "count", len(tasks))
+}
@@ -0,0 +1,276 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"go.uber.org/zap"
+	"golang.org/x/net/idna"
+)
+
+var ErrDomainBlocked = errors.New("domain is blocked")
+
+// domainRules — список правил для хостов. Строка файла задаёт одно правило:
+//
+//	example.com      точное совпадение
+//	*.example.com    сам домен и все его поддомены (то же, что .example.com)
+//	/^ex[0-9]+\.io$/ регулярное выражение
+//
+// Пустые строки и строки, начинающиеся с #, пропускаются.
+type domainRules struct {
+	exact    map[string]struct{}
+	suffixes []string
+	patterns []*regexp.Regexp
+}
+
+func parseDomainRules(r io.Reader) (*domainRules, error) {
+	rules := &domainRules{exact: make(map[string]struct{})}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		rule := strings.TrimSpace(scanner.Text())
+		if rule == "" || strings.HasPrefix(rule, "#") {
+			continue
+		}
+		if len(rule) > 1 && strings.HasPrefix(rule, "/") && strings.HasSuffix(rule, "/") {
+			re, err := regexp.Compile(rule[1 : len(rule)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			rules.patterns = append(rules.patterns, re)
+			continue
+		}
+
+		suffix := false
+		if strings.HasPrefix(rule, "*.") {
+			rule, suffix = rule[2:], true
+		} else if strings.HasPrefix(rule, ".") {
+			rule, suffix = rule[1:], true
+		}
+		host, err := canonicalHost(rule)
+		if err != nil || host == "" {
+			return nil, fmt.Errorf("line %d: invalid host %q", line, rule)
+		}
+		if suffix {
+			rules.suffixes = append(rules.suffixes, host)
+		} else {
+			rules.exact[host] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func loadDomainRules(path string) (*domainRules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules, err := parseDomainRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+func (r *domainRules) match(host string) bool {
+	if _, ok := r.exact[host]; ok {
+		return true
+	}
+	for _, suffix := range r.suffixes {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			return true
+		}
+	}
+	for _, re := range r.patterns {
+		if re.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalHost приводит хост к виду, в котором его сравнивают правила:
+// punycode в нижнем регистре без завершающей точки.
+func canonicalHost(host string) (string, error) {
+	host = strings.TrimSuffix(host, ".")
+	if strings.Contains(host, ":") {
+		return strings.ToLower(host), nil
+	}
+	host, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(host), nil
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// DomainPolicy проверяет хост ссылки по спискам разрешённых и запрещённых
+// доменов из локальных файлов. Запрещающий список важнее разрешающего; если
+// разрешающий список задан, ссылки на остальные домены не принимаются.
+// Файлы перечитываются при изменении; если новая версия содержит ошибку,
+// продолжают действовать прежние правила.
+// Нулевой указатель на DomainPolicy разрешает любые домены.
+type DomainPolicy struct {
+	allowPath string
+	denyPath  string
+
+	mu    sync.RWMutex
+	allow *domainRules
+	deny  *domainRules
+	files map[string]fileState
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewDomainPolicy загружает списки доменов; пустой путь отключает список.
+// При reloadInterval > 0 файлы проверяются на изменения с этим интервалом,
+// и владелец должен вызвать Close при остановке сервиса.
+func NewDomainPolicy(allowPath, denyPath string, reloadInterval time.Duration) (*DomainPolicy, error) {
+	p := &DomainPolicy{
+		allowPath: allowPath,
+		denyPath:  denyPath,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	if reloadInterval > 0 {
+		go p.watch(reloadInterval)
+	} else {
+		close(p.done)
+	}
+	return p, nil
+}
+
+// Reload перечитывает оба списка. Правила меняются, только если
+// оба файла прочитаны без ошибок.
+func (p *DomainPolicy) Reload() error {
+	files := make(map[string]fileState, 2)
+	load := func(path string) (*domainRules, error) {
+		if path == "" {
+			return nil, nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return loadDomainRules(path)
+	}
+
+	allow, err := load(p.allowPath)
+	if err != nil {
+		return err
+	}
+	deny, err := load(p.denyPath)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.allow, p.deny, p.files = allow, deny, files
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *DomainPolicy) changed() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for path, state := range p.files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return true
+		}
+		if !info.ModTime().Equal(state.modTime) || info.Size() != state.size {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *DomainPolicy) watch(interval time.Duration) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var failed bool
+	for {
+		select {
+		case <-ticker.C:
+			if !p.changed() && !failed {
+				continue
+			}
+			if err := p.Reload(); err != nil {
+				// об одной и той же ошибке сообщаем один раз
+				if !failed {
+					logger.Log.Error("Failed to reload domain lists, keeping previous rules", zap.Error(err))
+				}
+				failed = true
+				continue
+			}
+			failed = false
+			logger.Log.Info("Domain lists reloaded")
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Check возвращает ошибку, обёрнутую в ErrDomainBlocked, если сокращать
+// ссылку или переходить по ней нельзя из-за её домена.
+func (p *DomainPolicy) Check(rawURL string) error {
+	if p == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, errors.Unwrap(err))
+	}
+	host, err := canonicalHost(u.Hostname())
+	if err != nil {
+		// сохранённые до нормализации ссылки сравниваем как есть
+		host = strings.ToLower(u.Hostname())
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.deny != nil && p.deny.match(host) {
+		return fmt.Errorf("%w: %s", ErrDomainBlocked, host)
+	}
+	if p.allow != nil && !p.allow.match(host) {
+		return fmt.Errorf("%w: %s is not in the allowlist", ErrDomainBlocked, host)
+	}
+	return nil
+}
+
+// Close останавливает отслеживание изменений файлов.
+func (p *DomainPolicy) Close() {
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	<-p.done
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainRules(t *testing.T) {
+	rules, err := parseDomainRules(strings.NewReader(`
+# фишинг
+Evil.example
+*.phish.test
+.bad.test
+/^login-[0-9]+\.example\.org$/
+пример.рф
+`))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		host    string
+		matched bool
+	}{
+		{"evil.example", true},
+		{"sub.evil.example", false},
+		{"phish.test", true},
+		{"a.b.phish.test", true},
+		{"notphish.test", false},
+		{"x.bad.test", true},
+		{"login-42.example.org", true},
+		{"login-x.example.org", false},
+		{"xn--e1afmkfd.xn--p1ai", true},
+		{"example.com", false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.matched, rules.match(tc.host), tc.host)
+	}
+
+	_, err = parseDomainRules(strings.NewReader("ok.test\n/[/\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
+
+// writeDomainFile заменяет файл целиком через переименование, чтобы
+// перезагрузка не прочитала его наполовину записанным.
+func writeDomainFile(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte(content), 0o644))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func TestDomainPolicy(t *testing.T) {
+	dir := t.TempDir()
+	allowPath := filepath.Join(dir, "allow.txt")
+	denyPath := filepath.Join(dir, "deny.txt")
+	writeDomainFile(t, allowPath, "*.example.com\n")
+	writeDomainFile(t, denyPath, "evil.example.com\n")
+
+	policy, err := NewDomainPolicy(allowPath, denyPath, 10*time.Millisecond)
+	require.NoError(t, err)
+	defer policy.Close()
+
+	assert.NoError(t, policy.Check("https://example.com/a"))
+	assert.NoError(t, policy.Check("https://WWW.Example.com/a"))
+	assert.ErrorIs(t, policy.Check("https://evil.example.com/a"), ErrDomainBlocked)
+	assert.ErrorIs(t, policy.Check("https://other.test/"), ErrDomainBlocked)
+
+	// изменённый файл подхватывается без перезапуска
+	writeDomainFile(t, denyPath, "evil.example.com\nwww.example.com\n")
+	assert.Eventually(t, func() bool {
+		return policy.Check("https://www.example.com/") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// файл с ошибкой не отменяет действующие правила
+	writeDomainFile(t, denyPath, "/[/\n")
+	time.Sleep(50 * time.Millisecond)
+	assert.ErrorIs(t, policy.Check("https://www.example.com/"), ErrDomainBlocked)
+
+	_, err = NewDomainPolicy(filepath.Join(dir, "missing.txt"), "", 0)
+	assert.Error(t, err)
+
+	var disabled *DomainPolicy
+	assert.NoError(t, disabled.Check("https://evil.example.com/"))
+}
+
+func TestDomainPolicyHandlers(t *testing.T) {
+	denyPath := filepath.Join(t.TempDir(), "deny.txt")
+	writeDomainFile(t, denyPath, "phish.test\n")
+	policy, err := NewDomainPolicy("", denyPath, 0)
+	require.NoError(t, err)
+	defer policy.Close()
+
+	store := storage.InitMemoryStore()
+	require.NoError(t, store.AddURL(context.Background(), storage.URLRecord{ShortURL: "old", OriginalURL: "https://later.test/"}))
+	ts := httptest.NewServer(RootRouter(store, "http://localhost:8080", WithDomainPolicy(policy)))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/api/shorten", "application/json", bytes.NewBufferString(`{"url": "https://PHISH.test/login"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err = client.Get(ts.URL + "/old")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+
+	writeDomainFile(t, denyPath, "phish.test\nlater.test\n")
+	require.NoError(t, policy.Reload())
+	resp, err = client.Get(ts.URL + "/old")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnavailableForLegalReasons, resp.StatusCode)
+}
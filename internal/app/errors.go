@@ -0,0 +1,181 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Стабильные коды ошибок. Клиенты различают ошибки по ним, а не по тексту.
+const (
+	errCodeInvalidRequest = "invalid_request"
+	errCodeInvalidURL     = "invalid_url"
+	errCodeInvalidAlias   = "invalid_alias"
+	errCodeInvalidExpiry  = "invalid_expiry"
+	errCodeUnauthorized   = "unauthorized"
+	errCodeForbidden      = "forbidden"
+	errCodeDomainBlocked  = "domain_blocked"
+	errCodeNotFound       = "not_found"
+	errCodeAliasTaken     = "alias_taken"
+	errCodeConflict       = "already_shortened"
+	errCodeDeleted        = "deleted"
+	errCodeExpired        = "expired"
+	errCodeTooLarge       = "request_too_large"
+	errCodeMediaType      = "unsupported_media_type"
+	errCodeRateLimited    = "rate_limited"
+	errCodeInternal       = "internal_error"
+	errCodeUnavailable    = "unavailable"
+)
+
+const contentTypeProblem = "application/problem+json"
+
+var (
+	errUnauthenticated = errors.New("auth token required")
+	errForbidden       = errors.New("access denied")
+)
+
+// apiError — ошибка обработчика с HTTP-статусом и кодом для клиента.
+// Err — исходная ошибка: она нужна для errors.Is и журнала, но в ответ
+// не попадает.
+type apiError struct {
+	Status int
+	Code   string
+	Detail string
+
+	URL           string
+	Alias         string
+	CorrelationID string
+	Errors        []fieldError
+
+	Err error
+}
+
+func (e *apiError) Error() string {
+	return e.Detail
+}
+
+func (e *apiError) Unwrap() error {
+	return e.Err
+}
+
+// invalidRequest описывает запрос, который не удалось разобрать.
+func invalidRequest(format string, args ...any) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Code: errCodeInvalidRequest, Detail: fmt.Sprintf(format, args...)}
+}
+
+// bodyError описывает ошибку чтения или разбора тела запроса. Превышение
+// ограничения размера остаётся как есть и превращается в 413.
+func bodyError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return err
+	}
+	e := invalidRequest("cannot decode request body: %v", err)
+	e.Err = err
+	return e
+}
+
+// toAPIError сопоставляет ошибку хранилища, проверки или обработчика
+// статусу и коду ответа. Неизвестные ошибки становятся 500 без подробностей.
+func toAPIError(err error) *apiError {
+	var e *apiError
+	if errors.As(err, &e) {
+		return e
+	}
+	e = &apiError{Detail: err.Error(), Err: err}
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxErr):
+		e.Status, e.Code = http.StatusRequestEntityTooLarge, errCodeTooLarge
+		e.Detail = fmt.Sprintf("request body is larger than %d bytes", maxErr.Limit)
+	case errors.Is(err, storage.ErrNotFound):
+		e.Status, e.Code = http.StatusNotFound, errCodeNotFound
+		e.Detail = "short URL not found"
+	case errors.Is(err, storage.ErrDeleted):
+		e.Status, e.Code = http.StatusGone, errCodeDeleted
+		e.Detail = "short URL is deleted"
+	case errors.Is(err, storage.ErrExpired):
+		e.Status, e.Code = http.StatusGone, errCodeExpired
+		e.Detail = "short URL is expired"
+	case errors.Is(err, ErrAliasTaken):
+		e.Status, e.Code = http.StatusConflict, errCodeAliasTaken
+	case errors.Is(err, storage.ErrConflict):
+		e.Status, e.Code = http.StatusConflict, errCodeConflict
+		e.Detail = "URL is already shortened"
+	case errors.Is(err, ErrDomainBlocked):
+		e.Status, e.Code = http.StatusForbidden, errCodeDomainBlocked
+	case errors.Is(err, ErrInvalidURL):
+		e.Status, e.Code = http.StatusBadRequest, errCodeInvalidURL
+	case errors.Is(err, ErrInvalidAlias):
+		e.Status, e.Code = http.StatusBadRequest, errCodeInvalidAlias
+	case errors.Is(err, errInvalidExpiry):
+		e.Status, e.Code = http.StatusBadRequest, errCodeInvalidExpiry
+	case errors.Is(err, errUnauthenticated):
+		e.Status, e.Code = http.StatusUnauthorized, errCodeUnauthorized
+	case errors.Is(err, errForbidden):
+		e.Status, e.Code = http.StatusForbidden, errCodeForbidden
+	default:
+		e.Status, e.Code = http.StatusInternalServerError, errCodeInternal
+		e.Detail = "internal server error"
+	}
+	return e
+}
+
+// problem — тело ответа об ошибке по RFC 7807.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Code — стабильный код ошибки.
+	Code          string       `json:"code"`
+	URL           string       `json:"url,omitempty"`
+	Alias         string       `json:"alias,omitempty"`
+	CorrelationID string       `json:"correlation_id,omitempty"`
+	Errors        []fieldError `json:"errors,omitempty"`
+}
+
+// fieldError указывает на значение запроса, не прошедшее проверку.
+type fieldError struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+	Detail    string `json:"detail"`
+}
+
+// writeError отвечает на ошибку телом application/problem+json.
+func writeError(w http.ResponseWriter, err error) {
+	e := toAPIError(err)
+	w.Header().Set("Content-Type", contentTypeProblem)
+	w.WriteHeader(e.Status)
+	if err := json.NewEncoder(w).Encode(problem{
+		Type:          "about:blank",
+		Title:         http.StatusText(e.Status),
+		Status:        e.Status,
+		Detail:        e.Detail,
+		Code:          e.Code,
+		URL:           e.URL,
+		Alias:         e.Alias,
+		CorrelationID: e.CorrelationID,
+		Errors:        e.Errors,
+	}); err != nil {
+		logger.Log.Debug("error encoding response", zap.Error(err))
+	}
+}
+
+// writeTextError отвечает простым текстом для клиентов текстового API
+// и problem+json, если клиент просит JSON в Accept.
+func writeTextError(w http.ResponseWriter, r *http.Request, err error) {
+	accept := r.Header.Get("Accept")
+	if strings.Contains(accept, contentTypeProblem) || strings.Contains(accept, "application/json") {
+		writeError(w, err)
+		return
+	}
+	e := toAPIError(err)
+	http.Error(w, e.Detail, e.Status)
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteError(t *testing.T) {
+	testCases := []struct {
+		name            string
+		err             error
+		expectedCode    int
+		expectedErrCode string
+		expectedDetail  string
+	}{
+		{name: "Not found", err: fmt.Errorf("get URL: %w", storage.ErrNotFound), expectedCode: http.StatusNotFound, expectedErrCode: errCodeNotFound, expectedDetail: "short URL not found"},
+		{name: "Deleted", err: storage.ErrDeleted, expectedCode: http.StatusGone, expectedErrCode: errCodeDeleted, expectedDetail: "short URL is deleted"},
+		{name: "Expired", err: storage.ErrExpired, expectedCode: http.StatusGone, expectedErrCode: errCodeExpired, expectedDetail: "short URL is expired"},
+		{name: "Conflict", err: storage.ErrConflict, expectedCode: http.StatusConflict, expectedErrCode: errCodeConflict, expectedDetail: "URL is already shortened"},
+		{name: "Invalid URL", err: fmt.Errorf("%w: empty", ErrInvalidURL), expectedCode: http.StatusBadRequest, expectedErrCode: errCodeInvalidURL, expectedDetail: "invalid URL: empty"},
+		{name: "Too large", err: &http.MaxBytesError{Limit: 10}, expectedCode: http.StatusRequestEntityTooLarge, expectedErrCode: errCodeTooLarge, expectedDetail: "request body is larger than 10 bytes"},
+		{name: "Unknown error", err: errors.New("dial tcp 10.0.0.1:5432: connection refused"), expectedCode: http.StatusInternalServerError, expectedErrCode: errCodeInternal, expectedDetail: "internal server error"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeError(rec, tc.err)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, contentTypeProblem, rec.Header().Get("Content-Type"))
+			var p problem
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+			assert.Equal(t, problem{
+				Type:   "about:blank",
+				Title:  http.StatusText(tc.expectedCode),
+				Status: tc.expectedCode,
+				Detail: tc.expectedDetail,
+				Code:   tc.expectedErrCode,
+			}, p)
+		})
+	}
+}
+
+func TestWriteTextError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	rec := httptest.NewRecorder()
+	writeTextError(rec, req, storage.ErrNotFound)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "short URL not found\n", rec.Body.String())
+
+	req.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	writeTextError(rec, req, storage.ErrNotFound)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, contentTypeProblem, rec.Header().Get("Content-Type"))
+}
@@ -0,0 +1,224 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/auth"
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	pb "github.com/ma-shulgin/go-link-shortener/pkg/shortenerpb"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer реализует gRPC API поверх тех же компонентов, что и HTTP-обработчики.
+type grpcServer struct {
+	pb.UnimplementedShortenerServer
+
+	store     storage.URLStore
+	shortener *Shortener
+	baseURL   string
+	aliases   AliasPolicy
+	urls      urlChecker
+	domains   *DomainPolicy
+	deleter   *URLDeleter
+	maxItems  int
+}
+
+// NewGRPCServer создаёт gRPC-сервер с тем же хранилищем, генерацией
+// идентификаторов и правилами проверки ссылок, что и RootRouter. Для общей
+// авторизации с HTTP обоим нужно передать один и тот же WithSigner.
+func NewGRPCServer(urlStorage storage.URLStore, baseURL string, opts ...Option) *grpc.Server {
+	o := newOptions(urlStorage, opts)
+
+	interceptors := []grpc.UnaryServerInterceptor{logger.UnaryServerInterceptor, auth.UnaryServerInterceptor(o.signer)}
+	if o.limiter != nil {
+		interceptors = append(interceptors, o.limiter.UnaryServerInterceptor)
+	}
+	serverOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptors...),
+	}
+	if o.limits.MaxBodyBytes > 0 {
+		serverOpts = append(serverOpts, grpc.MaxRecvMsgSize(int(o.limits.MaxBodyBytes)))
+	}
+	srv := grpc.NewServer(serverOpts...)
+	pb.RegisterShortenerServer(srv, &grpcServer{
+		store:     urlStorage,
+		shortener: NewShortener(urlStorage, o.idGenerator),
+		baseURL:   baseURL,
+		aliases:   *o.aliases,
+		urls:      urlChecker{policy: *o.urls, domains: o.domains},
+		domains:   o.domains,
+		deleter:   o.deleter,
+		maxItems:  o.limits.MaxBatchItems,
+	})
+	return srv
+}
+
+func timeFromProto(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+// record проверяет поля запроса на сокращение так же, как HTTP-обработчики.
+func (s *grpcServer) record(ctx context.Context, rawURL, alias string, expiresAt *timestamppb.Timestamp, ttlSeconds int64, now time.Time) (storage.URLRecord, error) {
+	originalURL, err := s.urls.check(rawURL)
+	if errors.Is(err, ErrDomainBlocked) {
+		return storage.URLRecord{}, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		return storage.URLRecord{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	expires, err := expiryTime(timeFromProto(expiresAt), ttlSeconds, now)
+	if err != nil {
+		return storage.URLRecord{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if alias != "" {
+		if err := s.aliases.Validate(alias); err != nil {
+			return storage.URLRecord{}, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	return storage.URLRecord{
+		ShortURL:    alias,
+		OriginalURL: originalURL,
+		UserID:      auth.UserIDFromContext(ctx),
+		ExpiresAt:   expires,
+	}, nil
+}
+
+func (s *grpcServer) Shorten(ctx context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	record, err := s.record(ctx, req.GetUrl(), req.GetAlias(), req.GetExpiresAt(), req.GetTtlSeconds(), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := s.shortener.Shorten(ctx, record)
+	switch {
+	case errors.Is(err, ErrAliasTaken):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, storage.ErrConflict):
+		return &pb.ShortenResponse{ShortUrl: s.baseURL + "/" + id, Id: id, Existing: true}, nil
+	case err != nil:
+		logger.Log.Error("Failed to shorten URL", zap.Error(err))
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &pb.ShortenResponse{ShortUrl: s.baseURL + "/" + id, Id: id}, nil
+}
+
+func (s *grpcServer) ShortenBatch(ctx context.Context, req *pb.ShortenBatchRequest) (*pb.ShortenBatchResponse, error) {
+	items := req.GetItems()
+	if len(items) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "write at least one URL")
+	}
+	if s.maxItems > 0 && len(items) > s.maxItems {
+		return nil, status.Errorf(codes.ResourceExhausted, "batch has %d items, at most %d are allowed", len(items), s.maxItems)
+	}
+
+	now := time.Now()
+	records := make([]storage.URLRecord, 0, len(items))
+	for _, item := range items {
+		record, err := s.record(ctx, item.GetOriginalUrl(), item.GetAlias(), item.GetExpiresAt(), item.GetTtlSeconds(), now)
+		if err != nil {
+			st := status.Convert(err)
+			return nil, status.Errorf(st.Code(), "%s: %s", item.GetCorrelationId(), st.Message())
+		}
+		records = append(records, record)
+	}
+
+	ids, err := s.shortener.ShortenBatch(ctx, records)
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) && errors.Is(err, ErrAliasTaken) {
+		return nil, status.Errorf(codes.AlreadyExists, "%s: %v", items[batchErr.Index].GetCorrelationId(), ErrAliasTaken)
+	}
+	if err != nil {
+		logger.Log.Error("Failed to save URLs", zap.Error(err))
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	resp := &pb.ShortenBatchResponse{Items: make([]*pb.ShortenBatchResponse_Item, 0, len(items))}
+	for i, item := range items {
+		resp.Items = append(resp.Items, &pb.ShortenBatchResponse_Item{
+			CorrelationId: item.GetCorrelationId(),
+			ShortUrl:      s.baseURL + "/" + ids[i],
+		})
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) Resolve(ctx context.Context, req *pb.ResolveRequest) (*pb.ResolveResponse, error) {
+	originalURL, err := s.store.GetURL(ctx, req.GetId())
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return nil, status.Errorf(codes.NotFound, "short URL %q not found", req.GetId())
+	case errors.Is(err, storage.ErrDeleted), errors.Is(err, storage.ErrExpired):
+		return nil, status.Errorf(codes.NotFound, "short URL %q: %v", req.GetId(), err)
+	case err != nil:
+		logger.Log.Error("Failed to resolve URL", zap.Error(err))
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	if err := s.domains.Check(originalURL); errors.Is(err, ErrDomainBlocked) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return &pb.ResolveResponse{OriginalUrl: originalURL}, nil
+}
+
+func (s *grpcServer) ListUserURLs(ctx context.Context, _ *pb.ListUserURLsRequest) (*pb.ListUserURLsResponse, error) {
+	if !auth.IsAuthenticated(ctx) {
+		return nil, status.Error(codes.Unauthenticated, "auth token required")
+	}
+	records, err := s.store.GetUserURLs(ctx, auth.UserIDFromContext(ctx))
+	if err != nil {
+		logger.Log.Error("Failed to get user URLs", zap.Error(err))
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	resp := &pb.ListUserURLsResponse{Urls: make([]*pb.UserURL, 0, len(records))}
+	for _, record := range records {
+		url := &pb.UserURL{
+			ShortUrl:    s.baseURL + "/" + record.ShortURL,
+			OriginalUrl: record.OriginalURL,
+		}
+		if record.ExpiresAt != nil {
+			url.ExpiresAt = timestamppb.New(*record.ExpiresAt)
+		}
+		resp.Urls = append(resp.Urls, url)
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) DeleteURLs(ctx context.Context, req *pb.DeleteURLsRequest) (*pb.DeleteURLsResponse, error) {
+	if !auth.IsAuthenticated(ctx) {
+		return nil, status.Error(codes.Unauthenticated, "auth token required")
+	}
+	ids := req.GetIds()
+	if s.maxItems > 0 && len(ids) > s.maxItems {
+		return nil, status.Errorf(codes.ResourceExhausted, "batch has %d items, at most %d are allowed", len(ids), s.maxItems)
+	}
+
+	userID := auth.UserIDFromContext(ctx)
+	tasks := make([]storage.DeleteTask, 0, len(ids))
+	for _, id := range ids {
+		tasks = append(tasks, storage.DeleteTask{UserID: userID, ShortURL: id})
+	}
+	if err := s.deleter.Enqueue(ctx, tasks); err != nil {
+		logger.Log.Error("Failed to enqueue URLs for deletion", zap.Error(err))
+		return nil, status.Errorf(codes.Unavailable, "cannot delete URLs: %v", err)
+	}
+	return &pb.DeleteURLsResponse{}, nil
+}
+
+func (s *grpcServer) Ping(ctx context.Context, _ *pb.PingRequest) (*pb.PingResponse, error) {
+	if err := s.store.Ping(ctx); err != nil {
+		logger.Log.Error("Storage ping failed", zap.Error(err))
+		return nil, status.Error(codes.Unavailable, "storage is unavailable")
+	}
+	return &pb.PingResponse{}, nil
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/auth"
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	pb "github.com/ma-shulgin/go-link-shortener/pkg/shortenerpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func startGRPC(t *testing.T, store storage.URLStore, opts ...Option) pb.ShortenerClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	srv := NewGRPCServer(store, "http://localhost:8080", opts...)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewShortenerClient(conn)
+}
+
+func TestGRPCServer(t *testing.T) {
+	ctx := context.Background()
+	store := storage.InitMemoryStore()
+	signer := auth.NewSigner("secret")
+	client := startGRPC(t, store, WithSigner(signer))
+
+	// первый вызов без токена выдаёт новый токен в заголовке ответа
+	var header metadata.MD
+	resp, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://Example.com:443/a"}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.False(t, resp.GetExisting())
+	assert.Equal(t, "http://localhost:8080/"+resp.GetId(), resp.GetShortUrl())
+	tokens := header.Get(auth.MetadataKey)
+	require.Len(t, tokens, 1)
+	authCtx := metadata.AppendToOutgoingContext(ctx, auth.MetadataKey, tokens[0])
+
+	again, err := client.Shorten(authCtx, &pb.ShortenRequest{Url: "https://example.com/a"})
+	require.NoError(t, err)
+	assert.Equal(t, resp.GetId(), again.GetId())
+
+	_, err = client.Shorten(authCtx, &pb.ShortenRequest{Url: "https://example.com/b", Alias: "promo", TtlSeconds: 60})
+	require.NoError(t, err)
+	_, err = client.Shorten(authCtx, &pb.ShortenRequest{Url: "https://example.com/c", Alias: "promo"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = client.Shorten(authCtx, &pb.ShortenRequest{Url: "javascript:alert(1)"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	batch, err := client.ShortenBatch(authCtx, &pb.ShortenBatchRequest{Items: []*pb.ShortenBatchRequest_Item{
+		{CorrelationId: "1", OriginalUrl: "https://example.com/1"},
+		{CorrelationId: "2", OriginalUrl: "https://example.com/2", Alias: "second"},
+	}})
+	require.NoError(t, err)
+	require.Len(t, batch.GetItems(), 2)
+	assert.Equal(t, "http://localhost:8080/second", batch.GetItems()[1].GetShortUrl())
+	_, err = client.ShortenBatch(authCtx, &pb.ShortenBatchRequest{Items: []*pb.ShortenBatchRequest_Item{
+		{CorrelationId: "bad", OriginalUrl: "not a url"},
+	}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "bad: ")
+
+	resolved, err := client.Resolve(ctx, &pb.ResolveRequest{Id: "promo"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/b", resolved.GetOriginalUrl())
+	_, err = client.Resolve(ctx, &pb.ResolveRequest{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.ListUserURLs(ctx, &pb.ListUserURLsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	list, err := client.ListUserURLs(authCtx, &pb.ListUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.GetUrls(), 4)
+
+	// токен, выданный по gRPC, принимается HTTP API с тем же ключом подписи
+	ts := httptest.NewServer(RootRouter(store, "http://localhost:8080", WithSigner(signer)))
+	defer ts.Close()
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/user/urls", nil)
+	require.NoError(t, err)
+	req.Header.Set(auth.HeaderName, tokens[0])
+	httpResp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	httpResp.Body.Close()
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+
+	_, err = client.DeleteURLs(authCtx, &pb.DeleteURLsRequest{Ids: []string{"promo"}})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, err := client.Resolve(ctx, &pb.ResolveRequest{Id: "promo"})
+		return status.Code(err) == codes.NotFound
+	}, 3*time.Second, 20*time.Millisecond)
+
+	_, err = client.Ping(ctx, &pb.PingRequest{})
+	assert.NoError(t, err)
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitConfig{
+		Create:   RateLimit{Rate: 1, Burst: 2},
+		Redirect: RateLimit{Rate: 1, Burst: 1},
+	})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	client := startGRPC(t, storage.InitMemoryStore(), WithRateLimiter(limiter))
+	ctx := context.Background()
+
+	// каждый вызов без токена получает новый, но адрес клиента один
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com/1"})
+	require.NoError(t, err)
+	_, err = client.ShortenBatch(ctx, &pb.ShortenBatchRequest{Items: []*pb.ShortenBatchRequest_Item{
+		{CorrelationId: "1", OriginalUrl: "https://example.com/2"},
+	}})
+	require.NoError(t, err)
+	var header metadata.MD
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com/3"}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"1"}, header.Get("retry-after"))
+
+	// переходы ограничиваются отдельно, остальные методы — нет
+	_, err = client.Resolve(ctx, &pb.ResolveRequest{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.Resolve(ctx, &pb.ResolveRequest{Id: "missing"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = client.Ping(ctx, &pb.PingRequest{})
+	assert.NoError(t, err)
+
+	now = now.Add(time.Second)
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com/3"})
+	assert.NoError(t, err)
+}
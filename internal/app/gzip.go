@@ -2,19 +2,22 @@ package app
 
 import (
 	"compress/gzip"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
 
 	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"github.com/ma-shulgin/go-link-shortener/internal/metrics"
 	"go.uber.org/zap"
 )
 
 // compressWriter реализует интерфейс http.ResponseWriter и позволяет прозрачно для сервера
 // сжимать передаваемые данные и выставлять правильные HTTP-заголовки
 type compressWriter struct {
-	w  http.ResponseWriter
-	zw *gzip.Writer
+	w           http.ResponseWriter
+	zw          *gzip.Writer
+	wroteHeader bool
 }
 
 func newCompressWriter(w http.ResponseWriter) *compressWriter {
@@ -29,27 +32,46 @@ func (c *compressWriter) Header() http.Header {
 
 func (c *compressWriter) shallZip() bool {
 	contentType := c.Header().Get("Content-Type")
-	return strings.HasPrefix(contentType, "application/json") || strings.HasPrefix(contentType, "text/html")
+	return strings.HasPrefix(contentType, "application/json") || strings.HasPrefix(contentType, "text/html") ||
+		strings.HasPrefix(contentType, "application/x-ndjson") || strings.HasPrefix(contentType, "text/csv")
 }
 
 func (c *compressWriter) Write(p []byte) (int, error) {
-	if c.zw == nil && c.shallZip() {
-		c.zw = gzip.NewWriter(c.w)
-		c.w.Header().Set("Content-Encoding", "gzip")
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
 	}
 	if c.zw != nil {
-		return c.zw.Write(p)
+		n, err := c.zw.Write(p)
+		metrics.GzipBytes.Add(float64(n), "response", "uncompressed")
+		return n, err
 	}
 	return c.w.Write(p)
 }
 
+// WriteHeader решает, сжимать ли ответ: после отправки заголовков
+// выставить Content-Encoding уже нельзя, поэтому ответы с ошибками
+// отправляются без сжатия.
 func (c *compressWriter) WriteHeader(statusCode int) {
-	if c.zw == nil && statusCode < 300 && c.shallZip() {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	if statusCode < 300 && c.shallZip() {
+		c.zw = gzip.NewWriter(countingWriter{w: c.w})
 		c.w.Header().Set("Content-Encoding", "gzip")
 	}
 	c.w.WriteHeader(statusCode)
 }
 
+// Flush досылает клиенту уже сжатые данные, чтобы потоковые ответы
+// не копились в буфере gzip до конца обработки запроса.
+func (c *compressWriter) Flush() {
+	if c.zw != nil {
+		c.zw.Flush()
+	}
+	http.NewResponseController(c.w).Flush()
+}
+
 // Close закрывает gzip.Writer и досылает все данные из буфера.
 func (c *compressWriter) Close() error {
 	if c.zw == nil {
@@ -61,27 +83,69 @@ func (c *compressWriter) Close() error {
 	return nil
 }
 
+// countingWriter считает сжатые байты ответа, уходящие клиенту.
+type countingWriter struct {
+	w io.Writer
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	metrics.GzipBytes.Add(float64(n), "response", "compressed")
+	return n, err
+}
+
+// countingReader считает сжатые байты запроса, полученные от клиента.
+type countingReader struct {
+	r io.Reader
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	metrics.GzipBytes.Add(float64(n), "request", "compressed")
+	return n, err
+}
+
 // compressReader реализует интерфейс io.ReadCloser и позволяет прозрачно для сервера
-// декомпрессировать получаемые от клиента данные
+// декомпрессировать получаемые от клиента данные. Распакованные данные сверх
+// limit не отдаются: небольшой архив может распаковаться в гигабайты.
 type compressReader struct {
-	r  io.ReadCloser
-	zr *gzip.Reader
+	r     io.ReadCloser
+	zr    *gzip.Reader
+	limit int64 // 0 — без ограничения
+	read  int64
 }
 
-func newCompressReader(r io.ReadCloser) (*compressReader, error) {
-	zr, err := gzip.NewReader(r)
+func newCompressReader(r io.ReadCloser, limit int64) (*compressReader, error) {
+	zr, err := gzip.NewReader(countingReader{r: r})
 	if err != nil {
 		return nil, err
 	}
 
 	return &compressReader{
-		r:  r,
-		zr: zr,
+		r:     r,
+		zr:    zr,
+		limit: limit,
 	}, nil
 }
 
-func (c compressReader) Read(p []byte) (n int, err error) {
-	return c.zr.Read(p)
+func (c *compressReader) Read(p []byte) (n int, err error) {
+	if c.limit > 0 {
+		if c.read >= c.limit {
+			// проверяем, есть ли данные за пределом, не отдавая их
+			var probe [1]byte
+			if n, err := c.zr.Read(probe[:]); n == 0 {
+				return 0, err
+			}
+			return 0, &http.MaxBytesError{Limit: c.limit}
+		}
+		if rest := c.limit - c.read; int64(len(p)) > rest {
+			p = p[:rest]
+		}
+	}
+	n, err = c.zr.Read(p)
+	c.read += int64(n)
+	metrics.GzipBytes.Add(float64(n), "request", "uncompressed")
+	return n, err
 }
 
 func (c *compressReader) Close() error {
@@ -91,7 +155,15 @@ func (c *compressReader) Close() error {
 	return c.zr.Close()
 }
 
-func gzipMiddleware(h http.Handler) http.Handler {
+// gzipMiddleware сжимает ответы и распаковывает запросы, ограничивая
+// распакованное тело maxDecompressed байтами.
+func gzipMiddleware(maxDecompressed int64) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return gzipHandler(h, maxDecompressed)
+	}
+}
+
+func gzipHandler(h http.Handler, maxDecompressed int64) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// по умолчанию устанавливаем оригинальный http.ResponseWriter как тот,
 		// который будем передавать следующей функции
@@ -115,10 +187,14 @@ func gzipMiddleware(h http.Handler) http.Handler {
 		logger.Log.Debugln(r.Header)
 		if sendsGzip {
 			// оборачиваем тело запроса в io.Reader с поддержкой декомпрессии
-			cr, err := newCompressReader(r.Body)
+			cr, err := newCompressReader(r.Body, maxDecompressed)
 			if err != nil {
-				logger.Log.Error("Can't decode body ", zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				logger.Log.Debug("Can't decode body", zap.Error(err))
+				var maxErr *http.MaxBytesError
+				if !errors.As(err, &maxErr) {
+					err = invalidRequest("request body is not valid gzip")
+				}
+				writeError(w, err)
 				return
 			}
 			// меняем тело запроса на новое
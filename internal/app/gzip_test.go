@@ -0,0 +1,96 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressWriter(t *testing.T) {
+	testCases := []struct {
+		name         string
+		contentType  string
+		statusCode   int // 0 — WriteHeader не вызывается
+		expectedGzip bool
+	}{
+		{name: "Implicit OK", contentType: "application/json", expectedGzip: true},
+		{name: "Created", contentType: "application/json", statusCode: http.StatusCreated, expectedGzip: true},
+		{name: "Conflict", contentType: "application/json", statusCode: http.StatusConflict},
+		{name: "Bad request", contentType: "application/json", statusCode: http.StatusBadRequest},
+		{name: "Plain text", contentType: "text/plain", statusCode: http.StatusOK},
+	}
+
+	const body = `{"result": "http://localhost:8080/spring"}`
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := gzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				if tc.statusCode != 0 {
+					w.WriteHeader(tc.statusCode)
+				}
+				io.WriteString(w, body)
+			}), 0)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if tc.statusCode != 0 {
+				assert.Equal(t, tc.statusCode, rec.Code)
+			}
+			if !tc.expectedGzip {
+				assert.Empty(t, rec.Header().Get("Content-Encoding"))
+				assert.Equal(t, body, rec.Body.String())
+				return
+			}
+			assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+			zr, err := gzip.NewReader(rec.Body)
+			require.NoError(t, err)
+			data, err := io.ReadAll(zr)
+			require.NoError(t, err)
+			assert.Equal(t, body, string(data))
+		})
+	}
+}
+
+func TestGzipConflictBody(t *testing.T) {
+	// Bolt, в отличие от MemoryStore, сообщает о повторном сокращении
+	store, err := storage.InitBoltStore(filepath.Join(t.TempDir(), "urls.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	router := RootRouter(store, "http://localhost:8080")
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten",
+			strings.NewReader(`{"url": "https://example.com/sale"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post()
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	created, err := io.ReadAll(zr)
+	require.NoError(t, err)
+
+	// ответ 409 уходит несжатым: клиент должен прочитать короткий URL
+	rec = post()
+	require.Equal(t, http.StatusConflict, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.JSONEq(t, string(created), rec.Body.String())
+	assert.False(t, bytes.HasPrefix(rec.Body.Bytes(), []byte{0x1f, 0x8b}))
+}
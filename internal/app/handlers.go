@@ -3,25 +3,59 @@ package app
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/ma-shulgin/go-link-shortener/internal/analytics"
+	"github.com/ma-shulgin/go-link-shortener/internal/auth"
 	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"github.com/ma-shulgin/go-link-shortener/internal/metrics"
 	"github.com/ma-shulgin/go-link-shortener/internal/storage"
 	"go.uber.org/zap"
 )
 
-func RootRouter(urlStorage storage.URLStore, baseURL string) chi.Router {
+func RootRouter(urlStorage storage.URLStore, baseURL string, opts ...Option) chi.Router {
+	o := newOptions(urlStorage, opts)
+	if o.clicks == nil {
+		o.clicks = analytics.InitMemoryStore()
+	}
+	if o.pipeline == nil {
+		pipeline, err := analytics.NewPipeline(o.clicks, analytics.DefaultPipelineConfig())
+		if err != nil {
+			logger.Log.Fatal("Failed to start click pipeline: ", err)
+		}
+		o.pipeline = pipeline
+	}
+	urls := urlChecker{policy: *o.urls, domains: o.domains}
+
+	shortener := NewShortener(urlStorage, o.idGenerator)
+
 	r := chi.NewRouter()
+	r.Use(metrics.WithMetrics)
 	r.Use(logger.WithLogging)
-	r.Use(gzipMiddleware)
+	r.Use(limitBody(o.limits.MaxBodyBytes))
+	r.Use(gzipMiddleware(o.limits.MaxDecompressedBytes))
+	r.Use(auth.Middleware(o.signer, writeError))
+	r.Use(validateRequests(openAPI, r))
 
 	r.Get("/ping", handlePing(urlStorage))
-	r.Get("/{id}", handleRedirect(urlStorage))
-	r.Post("/", handleShorten(urlStorage, baseURL))
-	r.Post("/api/shorten", handleAPIShorten(urlStorage, baseURL))
-	r.Post("/api/shorten/batch", handleBatchShorten(urlStorage, baseURL))
+	r.Get("/api/openapi.json", handleOpenAPI())
+	r.Method(http.MethodGet, "/metrics", metrics.Default.Handler())
+	r.With(o.limiter.RedirectMiddleware).Get("/{id}", handleRedirect(urlStorage, o.pipeline, o.ipSalt, o.domains, o.limiter))
+	r.Group(func(r chi.Router) {
+		r.Use(o.limiter.CreateMiddleware)
+		r.Post("/", handleShorten(shortener, baseURL, urls))
+		r.Post("/api/shorten", handleAPIShorten(shortener, baseURL, *o.aliases, urls))
+		r.Post("/api/shorten/batch", handleBatchShorten(shortener, baseURL, *o.aliases, urls, o.limits.MaxBatchItems))
+		r.Post("/api/import", handleImport(urlStorage, shortener, baseURL, *o.aliases, urls, o.adminToken))
+	})
+	r.Get("/api/user/urls", handleUserURLs(urlStorage, baseURL))
+	r.Delete("/api/user/urls", handleDeleteUserURLs(o.deleter, o.limits.MaxBatchItems))
+	r.Get("/api/stats/{id}", handleStats(urlStorage, o.clicks, baseURL))
+	r.Get("/api/export", handleExport(urlStorage, o.adminToken))
 
 	return r
 }
@@ -29,7 +63,13 @@ func handlePing(urlStorage storage.URLStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		if err := urlStorage.Ping(ctx); err != nil {
-			http.Error(w, "Database ping failed", http.StatusInternalServerError)
+			logger.Log.Error("Storage ping failed", zap.Error(err))
+			writeError(w, &apiError{
+				Status: http.StatusInternalServerError,
+				Code:   errCodeUnavailable,
+				Detail: "storage is unavailable",
+				Err:    err,
+			})
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -37,27 +77,43 @@ func handlePing(urlStorage storage.URLStore) http.HandlerFunc {
 	}
 }
 
-func handleRedirect(urlStorage storage.URLStore) http.HandlerFunc {
+func handleRedirect(urlStorage storage.URLStore, clicks *analytics.Pipeline, ipSalt string, domains *DomainPolicy, limiter *RateLimiter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx:= r.Context()
+		ctx := r.Context()
 		urlID := chi.URLParam(r, "id")
-		if originalURL, ok := urlStorage.GetURL(ctx,urlID); ok {
-			http.Redirect(w, r, originalURL, http.StatusTemporaryRedirect)
+		originalURL, err := urlStorage.GetURL(ctx, urlID)
+		if err != nil {
+			e := toAPIError(err)
+			if e.Status == http.StatusInternalServerError {
+				logger.Log.Error("Failed to resolve URL", zap.Error(err))
+			}
+			writeError(w, e)
 			return
 		}
-		http.Error(w, "Bad request", http.StatusBadRequest)
+		// домен могли запретить уже после сокращения ссылки
+		if err := domains.Check(originalURL); errors.Is(err, ErrDomainBlocked) {
+			e := toAPIError(err)
+			e.Status = http.StatusUnavailableForLegalReasons
+			writeError(w, e)
+			return
+		}
+		clicks.Enqueue(ctx, newClick(r, urlID, ipSalt, limiter))
+		http.Redirect(w, r, originalURL, http.StatusTemporaryRedirect)
 	}
 }
 
 type shortenRequest struct {
-	URL string `json:"url"`
+	URL        string     `json:"url"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds int64      `json:"ttl_seconds,omitempty"`
+	Alias      string     `json:"alias,omitempty"`
 }
 
 type shortenResponse struct {
 	Result string `json:"result"`
 }
 
-func handleAPIShorten(urlStorage storage.URLStore, baseURL string) http.HandlerFunc {
+func handleAPIShorten(shortener *Shortener, baseURL string, aliases AliasPolicy, urls urlChecker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		logger.Log.Debug("decoding request")
@@ -65,23 +121,56 @@ func handleAPIShorten(urlStorage storage.URLStore, baseURL string) http.HandlerF
 		dec := json.NewDecoder(r.Body)
 		if err := dec.Decode(&req); err != nil {
 			logger.Log.Error("cannot decode request JSON body", zap.Error(err))
-			w.WriteHeader(http.StatusBadRequest)
+			writeError(w, bodyError(err))
 			return
 		}
 		defer r.Body.Close()
 
-		urlID := GenerateShortURLID(req.URL)
-		
-		w.Header().Set("Content-Type", "application/json")
-		err := urlStorage.AddURL(ctx, req.URL, urlID)
+		originalURL, err := urls.check(req.URL)
 		if err != nil {
-			if errors.Is(err, storage.ErrConflict) {
-				w.WriteHeader(http.StatusConflict)
-			} else {
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			e := toAPIError(err)
+			e.URL = req.URL
+			writeError(w, e)
+			return
+		}
+
+		expiresAt, err := expiryTime(req.ExpiresAt, req.TTLSeconds, time.Now())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if req.Alias != "" {
+			if err := aliases.Validate(req.Alias); err != nil {
+				e := toAPIError(err)
+				e.Alias = req.Alias
+				writeError(w, e)
 				return
 			}
-		} else {
+		}
+
+		urlID, err := shortener.Shorten(ctx, storage.URLRecord{
+			ShortURL:    req.Alias,
+			OriginalURL: originalURL,
+			UserID:      auth.UserIDFromContext(ctx),
+			ExpiresAt:   expiresAt,
+		})
+		switch {
+		case errors.Is(err, ErrAliasTaken):
+			e := toAPIError(err)
+			e.Alias = req.Alias
+			writeError(w, e)
+			return
+		case errors.Is(err, storage.ErrConflict):
+			// повторное сокращение не ошибка: клиент получает прежнюю ссылку
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+		case err != nil:
+			logger.Log.Error("Failed to shorten URL", zap.Error(err))
+			writeError(w, err)
+			return
+		default:
+			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusCreated)
 		}
 
@@ -98,9 +187,31 @@ func handleAPIShorten(urlStorage storage.URLStore, baseURL string) http.HandlerF
 	}
 }
 
+var errInvalidExpiry = errors.New("invalid expiry")
+
+// expiryTime вычисляет момент истечения ссылки по полям expires_at и
+// ttl_seconds запроса. nil означает бессрочную ссылку.
+func expiryTime(expiresAt *time.Time, ttlSeconds int64, now time.Time) (*time.Time, error) {
+	switch {
+	case expiresAt != nil && ttlSeconds != 0:
+		return nil, fmt.Errorf("%w: expires_at and ttl_seconds are mutually exclusive", errInvalidExpiry)
+	case ttlSeconds < 0:
+		return nil, fmt.Errorf("%w: ttl_seconds must be positive", errInvalidExpiry)
+	case ttlSeconds > 0:
+		t := now.Add(time.Duration(ttlSeconds) * time.Second)
+		return &t, nil
+	case expiresAt != nil && !expiresAt.After(now):
+		return nil, fmt.Errorf("%w: expires_at must be in the future", errInvalidExpiry)
+	}
+	return expiresAt, nil
+}
+
 type batchRequest struct {
-	CorrelationID string `json:"correlation_id"`
-	OriginalURL   string `json:"original_url"`
+	CorrelationID string     `json:"correlation_id"`
+	OriginalURL   string     `json:"original_url"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds    int64      `json:"ttl_seconds,omitempty"`
+	Alias         string     `json:"alias,omitempty"`
 }
 
 type batchResponse struct {
@@ -108,79 +219,214 @@ type batchResponse struct {
 	ShortURL      string `json:"short_url"`
 }
 
-func handleBatchShorten(urlStorage storage.URLStore, baseURL string) http.HandlerFunc {
+func handleBatchShorten(shortener *Shortener, baseURL string, aliases AliasPolicy, urls urlChecker, maxItems int) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx:= r.Context()
+		ctx := r.Context()
 		var req []batchRequest
 		dec := json.NewDecoder(r.Body)
 		if err := dec.Decode(&req); err != nil {
 			logger.Log.Error("cannot decode request JSON body", zap.Error(err))
-			w.WriteHeader(http.StatusBadRequest)
+			writeError(w, bodyError(err))
 			return
 		}
 		defer r.Body.Close()
 
-		var batchRes []batchResponse
-		var urlsToAdd []storage.URLRecord
+		if len(req) == 0 {
+			writeError(w, invalidRequest("write at least one URL"))
+			return
+		}
+		if err := checkBatchSize(len(req), maxItems); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		userID := auth.UserIDFromContext(ctx)
+		urlsToAdd := make([]storage.URLRecord, 0, len(req))
 
+		now := time.Now()
 		for _, req := range req {
-			urlID := GenerateShortURLID(req.OriginalURL)
+			originalURL, err := urls.check(req.OriginalURL)
+			if err != nil {
+				e := toAPIError(err)
+				e.URL, e.CorrelationID = req.OriginalURL, req.CorrelationID
+				writeError(w, e)
+				return
+			}
+			expiresAt, err := expiryTime(req.ExpiresAt, req.TTLSeconds, now)
+			if err != nil {
+				e := toAPIError(err)
+				e.CorrelationID = req.CorrelationID
+				writeError(w, e)
+				return
+			}
+			if req.Alias != "" {
+				if err := aliases.Validate(req.Alias); err != nil {
+					e := toAPIError(err)
+					e.Alias, e.CorrelationID = req.Alias, req.CorrelationID
+					writeError(w, e)
+					return
+				}
+			}
 			urlsToAdd = append(urlsToAdd, storage.URLRecord{
-				ShortURL:    urlID,
-				OriginalURL: req.OriginalURL,
+				ShortURL:    req.Alias,
+				OriginalURL: originalURL,
+				UserID:      userID,
+				ExpiresAt:   expiresAt,
 			})
+		}
+
+		ids, err := shortener.ShortenBatch(ctx, urlsToAdd)
+		var batchErr *BatchError
+		if errors.As(err, &batchErr) && errors.Is(err, ErrAliasTaken) {
+			item := req[batchErr.Index]
+			e := toAPIError(ErrAliasTaken)
+			e.Alias, e.CorrelationID = item.Alias, item.CorrelationID
+			writeError(w, e)
+			return
+		}
+		if err != nil {
+			logger.Log.Error("Failed to save URLs", zap.Error(err))
+			writeError(w, err)
+			return
+		}
+
+		batchRes := make([]batchResponse, 0, len(req))
+		for i, req := range req {
 			batchRes = append(batchRes, batchResponse{
 				CorrelationID: req.CorrelationID,
-				ShortURL:      baseURL + "/" + urlID,
+				ShortURL:      baseURL + "/" + ids[i],
 			})
 		}
-		if len(urlsToAdd) == 0 {
-			http.Error(w, "Write at least one URL", http.StatusBadRequest)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(batchRes); err != nil {
+			logger.Log.Debug("error encoding response", zap.Error(err))
+			return
+		}
+	}
+}
+
+type userURLResponse struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+}
+
+func handleUserURLs(urlStorage storage.URLStore, baseURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if !auth.IsAuthenticated(ctx) {
+			writeError(w, errUnauthenticated)
 			return
 		}
 
-		if err := urlStorage.AddURLBatch(ctx, urlsToAdd); err != nil {
-			logger.Log.Error("Failed to save URLs", zap.Error(err))
-			w.WriteHeader(http.StatusBadRequest)
+		records, err := urlStorage.GetUserURLs(ctx, auth.UserIDFromContext(ctx))
+		if err != nil {
+			logger.Log.Error("Failed to get user URLs", zap.Error(err))
+			writeError(w, err)
+			return
+		}
+		if len(records) == 0 {
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
+		resp := make([]userURLResponse, 0, len(records))
+		for _, record := range records {
+			resp = append(resp, userURLResponse{
+				ShortURL:    baseURL + "/" + record.ShortURL,
+				OriginalURL: record.OriginalURL,
+			})
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(http.StatusOK)
 
 		enc := json.NewEncoder(w)
-		if err := enc.Encode(batchRes); err != nil {
+		if err := enc.Encode(resp); err != nil {
 			logger.Log.Debug("error encoding response", zap.Error(err))
 			return
 		}
 	}
 }
 
-func handleShorten(urlStorage storage.URLStore, baseURL string) http.HandlerFunc {
+func handleDeleteUserURLs(deleter *URLDeleter, maxItems int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if !auth.IsAuthenticated(ctx) {
+			writeError(w, errUnauthenticated)
+			return
+		}
+
+		var ids []string
+		dec := json.NewDecoder(r.Body)
+		if err := dec.Decode(&ids); err != nil {
+			logger.Log.Error("cannot decode request JSON body", zap.Error(err))
+			writeError(w, bodyError(err))
+			return
+		}
+		defer r.Body.Close()
+		if err := checkBatchSize(len(ids), maxItems); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		userID := auth.UserIDFromContext(ctx)
+		tasks := make([]storage.DeleteTask, 0, len(ids))
+		for _, id := range ids {
+			tasks = append(tasks, storage.DeleteTask{UserID: userID, ShortURL: id})
+		}
+
+		if err := deleter.Enqueue(ctx, tasks); err != nil {
+			logger.Log.Error("Failed to enqueue URLs for deletion", zap.Error(err))
+			writeError(w, &apiError{
+				Status: http.StatusServiceUnavailable,
+				Code:   errCodeUnavailable,
+				Detail: "cannot delete URLs: " + err.Error(),
+				Err:    err,
+			})
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func handleShorten(shortener *Shortener, baseURL string, urls urlChecker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		originalURL, err := io.ReadAll(r.Body)
+		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, "Error reading request body", http.StatusInternalServerError)
+			writeTextError(w, r, bodyError(err))
 			return
 		}
 		r.Body.Close()
 
-		urlID := GenerateShortURLID(string(originalURL))
-		shortenedURL := baseURL + "/" + urlID
-		w.Header().Set("Content-Type", "text/plain")
-
-		err = urlStorage.AddURL(ctx, string(originalURL), urlID)
+		originalURL, err := urls.check(string(body))
 		if err != nil {
-			if errors.Is(err, storage.ErrConflict) {
-				w.WriteHeader(http.StatusConflict)
-			} else {
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-				return
-			}
-		} else {
+			e := toAPIError(err)
+			e.URL = string(body)
+			writeTextError(w, r, e)
+			return
+		}
+
+		urlID, err := shortener.Shorten(ctx, storage.URLRecord{
+			OriginalURL: originalURL,
+			UserID:      auth.UserIDFromContext(ctx),
+		})
+		switch {
+		case errors.Is(err, storage.ErrConflict):
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusConflict)
+		case err != nil:
+			logger.Log.Error("Failed to shorten URL", zap.Error(err))
+			writeTextError(w, r, err)
+			return
+		default:
+			w.Header().Set("Content-Type", "text/plain")
 			w.WriteHeader(http.StatusCreated)
 		}
-		w.Write([]byte(shortenedURL))
+		w.Write([]byte(baseURL + "/" + urlID))
 	}
 }
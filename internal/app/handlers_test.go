@@ -2,13 +2,21 @@ package app
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ma-shulgin/go-link-shortener/internal/analytics"
 	"github.com/ma-shulgin/go-link-shortener/internal/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -29,7 +37,7 @@ func TestRootRouter(t *testing.T) {
 
 	originalURL := "https://example.com"
 	urlID := GenerateShortURLID(originalURL)
-	err = store.AddURL(context.Background(), originalURL, urlID)
+	err = store.AddURL(context.Background(), storage.URLRecord{OriginalURL: originalURL, ShortURL: urlID})
 	require.NoError(t, err)
 
 	ts := httptest.NewServer(RootRouter(store, "http://localhost:8080"))
@@ -63,7 +71,7 @@ func TestRootRouter(t *testing.T) {
 			method:       http.MethodPost,
 			path:         "/",
 			body:         originalURL,
-			expectedCode: http.StatusCreated,
+			expectedCode: http.StatusConflict,
 			expectedBody: "http://localhost:8080/" + urlID,
 			responseType: "text",
 		},
@@ -87,7 +95,7 @@ func TestRootRouter(t *testing.T) {
 			name:         "Wrong URL",
 			method:       http.MethodGet,
 			path:         "/ntexst66",
-			expectedCode: http.StatusBadRequest,
+			expectedCode: http.StatusNotFound,
 			expectedBody: "",
 			responseType: "",
 		},
@@ -111,7 +119,7 @@ func TestRootRouter(t *testing.T) {
 			method:       http.MethodPost,
 			path:         "/api/shorten",
 			body:         `{"url": "https://example.com"}`,
-			expectedCode: http.StatusCreated,
+			expectedCode: http.StatusConflict,
 			expectedBody: `{"result": "http://localhost:8080/` + urlID + `"}`,
 			responseType: "json",
 		},
@@ -159,3 +167,527 @@ func TestRootRouter(t *testing.T) {
 		})
 	}
 }
+
+func TestUserURLs(t *testing.T) {
+	store := storage.InitMemoryStore()
+	ts := httptest.NewServer(RootRouter(store, "http://localhost:8080"))
+	defer ts.Close()
+
+	newClient := func() *http.Client {
+		jar, err := cookiejar.New(nil)
+		require.NoError(t, err)
+		return &http.Client{Jar: jar}
+	}
+
+	get := func(client *http.Client) *http.Response {
+		resp, err := client.Get(ts.URL + "/api/user/urls")
+		require.NoError(t, err)
+		return resp
+	}
+
+	owner := newClient()
+
+	resp := get(owner)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "client without token must be rejected")
+
+	resp = get(owner)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode, "user without links must get 204")
+
+	originalURL := "https://example.com/owned"
+	resp, err := owner.Post(ts.URL+"/api/shorten", "application/json", bytes.NewBufferString(`{"url": "`+originalURL+`"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = get(owner)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `[{"short_url": "http://localhost:8080/`+GenerateShortURLID(originalURL)+`", "original_url": "`+originalURL+`"}]`, string(body))
+
+	stranger := newClient()
+	resp = get(stranger)
+	resp.Body.Close()
+	resp = get(stranger)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode, "links of other users must not be listed")
+}
+
+func TestDeleteUserURLs(t *testing.T) {
+	store := storage.InitMemoryStore()
+	deleter := NewURLDeleter(store)
+	ts := httptest.NewServer(RootRouter(store, "http://localhost:8080", WithDeleter(deleter)))
+	defer ts.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	owner := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	ownedURL := "https://example.com/to-delete"
+	keptURL := "https://example.com/to-keep"
+	for _, u := range []string{ownedURL, keptURL} {
+		resp, err := owner.Post(ts.URL+"/", "text/plain", bytes.NewBufferString(u))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+	foreignURL := "https://example.com/foreign"
+	require.NoError(t, store.AddURL(context.Background(), storage.URLRecord{
+		OriginalURL: foreignURL,
+		ShortURL:    GenerateShortURLID(foreignURL),
+		UserID:      "someone-else",
+	}))
+
+	body := `["` + GenerateShortURLID(ownedURL) + `", "` + GenerateShortURLID(foreignURL) + `"]`
+	req, err := http.NewRequest(http.MethodDelete, ts.URL+"/api/user/urls", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	resp, err := owner.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	deleter.Close()
+
+	expected := map[string]int{
+		ownedURL:   http.StatusGone,
+		keptURL:    http.StatusTemporaryRedirect,
+		foreignURL: http.StatusTemporaryRedirect,
+	}
+	for u, code := range expected {
+		resp, err := owner.Get(ts.URL + "/" + GenerateShortURLID(u))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, code, resp.StatusCode, "unexpected status for %s", u)
+	}
+}
+
+func TestExpiringURLs(t *testing.T) {
+	store := storage.InitMemoryStore()
+	ts := httptest.NewServer(RootRouter(store, "http://localhost:8080"))
+	defer ts.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	expiredURL := "https://example.com/expired"
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, store.AddURL(context.Background(), storage.URLRecord{
+		OriginalURL: expiredURL,
+		ShortURL:    GenerateShortURLID(expiredURL),
+		ExpiresAt:   &past,
+	}))
+
+	resp, err := client.Get(ts.URL + "/" + GenerateShortURLID(expiredURL))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusGone, resp.StatusCode)
+
+	purged, err := store.PurgeExpired(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	testCases := []struct {
+		name         string
+		body         string
+		expectedCode int
+	}{
+		{
+			name:         "TTL",
+			body:         `{"url": "https://example.com/ttl", "ttl_seconds": 3600}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Absolute expiry",
+			body:         `{"url": "https://example.com/abs", "expires_at": "` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Negative TTL",
+			body:         `{"url": "https://example.com/neg", "ttl_seconds": -1}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Expiry in the past",
+			body:         `{"url": "https://example.com/past", "expires_at": "` + past.Format(time.RFC3339) + `"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Both TTL and expiry",
+			body:         `{"url": "https://example.com/both", "ttl_seconds": 10, "expires_at": "` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := client.Post(ts.URL+"/api/shorten", "application/json", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestAliases(t *testing.T) {
+	store := storage.InitMemoryStore()
+	ts := httptest.NewServer(RootRouter(store, "http://localhost:8080"))
+	defer ts.Close()
+
+	testCases := []struct {
+		name         string
+		path         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Free alias",
+			path:         "/api/shorten",
+			body:         `{"url": "https://example.com/sale", "alias": "spring-sale"}`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"result": "http://localhost:8080/spring-sale"}`,
+		},
+		{
+			name:         "Alias taken by another URL",
+			path:         "/api/shorten",
+			body:         `{"url": "https://example.com/other", "alias": "spring-sale"}`,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"type": "about:blank", "title": "Conflict", "status": 409, "code": "alias_taken",
+				"detail": "alias is already used for another URL", "alias": "spring-sale"}`,
+		},
+		{
+			name:         "Reserved alias",
+			path:         "/api/shorten",
+			body:         `{"url": "https://example.com/other", "alias": "PING"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Alias with forbidden characters",
+			path:         "/api/shorten",
+			body:         `{"url": "https://example.com/other", "alias": "a/b/c"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Batch alias",
+			path:         "/api/shorten/batch",
+			body:         `[{"correlation_id": "1", "original_url": "https://example.com/summer", "alias": "summer"}]`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `[{"correlation_id": "1", "short_url": "http://localhost:8080/summer"}]`,
+		},
+		{
+			name: "Batch alias used twice",
+			path: "/api/shorten/batch",
+			body: `[{"correlation_id": "1", "original_url": "https://example.com/a", "alias": "autumn"},
+				{"correlation_id": "2", "original_url": "https://example.com/b", "alias": "autumn"}]`,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"type": "about:blank", "title": "Conflict", "status": 409, "code": "alias_taken",
+				"detail": "alias is already used for another URL", "alias": "autumn", "correlation_id": "2"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := http.Post(ts.URL+tc.path, "application/json", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			if tc.expectedBody != "" {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+
+	_, err := store.GetURL(context.Background(), "autumn")
+	assert.ErrorIs(t, err, storage.ErrNotFound, "rejected batch must not be stored")
+}
+
+func TestStats(t *testing.T) {
+	store := storage.InitMemoryStore()
+	clicks := analytics.InitMemoryStore()
+	pipeline, err := analytics.NewPipeline(clicks, analytics.DefaultPipelineConfig())
+	require.NoError(t, err)
+	ts := httptest.NewServer(RootRouter(store, "http://localhost:8080", WithAnalytics(clicks, ""), WithClickPipeline(pipeline)))
+	defer ts.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	owner := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := owner.Post(ts.URL+"/", "text/plain", bytes.NewBufferString("https://example.com/tracked"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	urlID := GenerateShortURLID("https://example.com/tracked")
+
+	for _, referrer := range []string{"https://a.example", "https://a.example", "https://b.example"} {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/"+urlID, nil)
+		require.NoError(t, err)
+		req.Header.Set("Referer", referrer)
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	}
+
+	pipeline.Close()
+	assert.Equal(t, uint64(3), pipeline.Stats().Flushed)
+
+	resp, err = owner.Get(ts.URL + "/api/stats/" + urlID)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{
+		"short_url": "http://localhost:8080/`+urlID+`",
+		"total_clicks": 3,
+		"unique_visitors": 1,
+		"clicks_per_day": [{"date": "`+time.Now().UTC().Format("2006-01-02")+`", "clicks": 3}],
+		"top_referrers": [{"referrer": "https://a.example", "clicks": 2}, {"referrer": "https://b.example", "clicks": 1}]
+	}`, string(body))
+
+	resp, err = owner.Get(ts.URL + "/api/stats/unknown1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// после удаления статистика остаётся доступной владельцу
+	req, err := http.NewRequest(http.MethodDelete, ts.URL+"/api/user/urls", strings.NewReader(`["`+urlID+`"]`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = owner.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Eventually(t, func() bool {
+		_, err := store.GetURL(context.Background(), urlID)
+		return errors.Is(err, storage.ErrDeleted)
+	}, 3*time.Second, 10*time.Millisecond)
+	resp, err = owner.Get(ts.URL + "/api/stats/" + urlID)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// и после истечения срока действия
+	record, err := store.GetRecord(context.Background(), urlID)
+	require.NoError(t, err)
+	expired := time.Now().Add(-time.Minute)
+	require.NoError(t, store.AddURL(context.Background(), storage.URLRecord{
+		ShortURL: "expired1", OriginalURL: "https://example.com/expired", UserID: record.UserID, ExpiresAt: &expired,
+	}))
+	resp, err = owner.Get(ts.URL + "/expired1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusGone, resp.StatusCode)
+	resp, err = owner.Get(ts.URL + "/api/stats/expired1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	strangerJar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	stranger := &http.Client{Jar: strangerJar}
+	for _, expected := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		resp, err = stranger.Get(ts.URL + "/api/stats/" + urlID)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, expected, resp.StatusCode)
+	}
+}
+
+// batchCountingStore считает одиночные и пакетные записи.
+type batchCountingStore struct {
+	storage.URLStore
+	adds, batches int
+}
+
+func (s *batchCountingStore) AddURL(ctx context.Context, record storage.URLRecord) error {
+	s.adds++
+	return s.URLStore.AddURL(ctx, record)
+}
+
+func (s *batchCountingStore) AddURLBatch(ctx context.Context, urls []storage.URLRecord) error {
+	s.batches++
+	return s.URLStore.AddURLBatch(ctx, urls)
+}
+
+func TestImportBatchesGeneratedIDs(t *testing.T) {
+	store := &batchCountingStore{URLStore: storage.InitMemoryStore()}
+	ts := httptest.NewServer(RootRouter(store, "http://localhost:8080"))
+	defer ts.Close()
+
+	var body strings.Builder
+	for i := 0; i < importChunkSize+1; i++ {
+		fmt.Fprintf(&body, "{\"original_url\":\"https://example.com/%d\"}\n", i)
+	}
+	body.WriteString(`{"original_url":"https://example.com/0"}` + "\n")
+	resp, err := http.Post(ts.URL+"/api/import", "application/x-ndjson", strings.NewReader(body.String()))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var report importReport
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	assert.Equal(t, importChunkSize+1, report.Created)
+	assert.Equal(t, 1, report.Conflicts, "duplicate URL must not be created twice")
+	assert.Equal(t, 0, store.adds)
+	assert.Equal(t, 2, store.batches)
+}
+
+func TestExportImport(t *testing.T) {
+	store := storage.InitMemoryStore()
+	ts := httptest.NewServer(RootRouter(store, "http://localhost:8080", WithAdminToken("secret")))
+	defer ts.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+
+	do := func(method, path, contentType string, body io.Reader, header http.Header) (int, string) {
+		req, err := http.NewRequest(method, ts.URL+path, body)
+		require.NoError(t, err)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+
+	require.NoError(t, store.AddURL(context.Background(), storage.URLRecord{ShortURL: "taken", OriginalURL: "https://example.org"}))
+	require.NoError(t, store.AddURL(context.Background(), storage.URLRecord{ShortURL: "gone", OriginalURL: "https://example.org/gone", UserID: "someone", DeletedFlag: true}))
+	expired := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	require.NoError(t, store.AddURL(context.Background(), storage.URLRecord{ShortURL: "old", OriginalURL: "https://example.org/old", UserID: "someone", ExpiresAt: &expired}))
+
+	ndjson := `{"id":"first","original_url":"https://example.com/1"}
+not json
+{"original_url":"https://example.com/2"}
+{"id":"taken","original_url":"https://example.com/3"}
+{"id":"api","original_url":"https://example.com/4"}
+{"id":"second","original_url":"https://example.com/5","user_id":"someone","is_deleted":true}
+`
+	status, body := do(http.MethodPost, "/api/import", "application/x-ndjson", strings.NewReader(ndjson), nil)
+	require.Equal(t, http.StatusOK, status, body)
+	var report importReport
+	require.NoError(t, json.Unmarshal([]byte(body), &report))
+	assert.Equal(t, 3, report.Created)
+	assert.Equal(t, 1, report.Conflicts)
+	assert.Equal(t, 2, report.Invalid)
+	statuses := make(map[int]string)
+	for _, line := range report.Lines {
+		statuses[line.Line] = line.Status
+	}
+	assert.Equal(t, map[int]string{1: "created", 2: "invalid", 3: "created", 4: "conflict", 5: "invalid", 6: "created"}, statuses)
+
+	// повторная загрузка ничего не создаёт
+	status, body = do(http.MethodPost, "/api/import", "application/x-ndjson", strings.NewReader(ndjson), nil)
+	require.Equal(t, http.StatusOK, status, body)
+	report = importReport{}
+	require.NoError(t, json.Unmarshal([]byte(body), &report))
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, 4, report.Conflicts)
+
+	// без токена администратора владелец и флаг удаления берутся из запроса
+	_, err = store.GetURL(context.Background(), "second")
+	require.NoError(t, err)
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err = zw.Write([]byte("original_url,id,expires_at\nhttps://example.com/6,third,\nhttps://example.com/7,fourth,yesterday\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	status, body = do(http.MethodPost, "/api/import", "text/csv", &gz, http.Header{"Content-Encoding": {"gzip"}})
+	require.Equal(t, http.StatusOK, status, body)
+	require.NoError(t, json.Unmarshal([]byte(body), &report))
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Invalid)
+	assert.Equal(t, 3, report.Lines[1].Line)
+
+	// незакрытая кавычка — ошибка строки, а не всего запроса
+	status, body = do(http.MethodPost, "/api/import", "text/csv", strings.NewReader("original_url\n\"https://a.example\n"), nil)
+	require.Equal(t, http.StatusOK, status, body)
+	report = importReport{}
+	require.NoError(t, json.Unmarshal([]byte(body), &report))
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, 1, report.Invalid)
+	assert.Equal(t, []importLine{{Line: 2, Status: importInvalid, Error: `invalid record: extraneous or missing " in quoted-field`}}, report.Lines)
+
+	status, body = do(http.MethodGet, "/api/export", "", nil, nil)
+	require.Equal(t, http.StatusOK, status)
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	assert.Len(t, lines, 4)
+	assert.Contains(t, lines, `{"id":"first","original_url":"https://example.com/1"}`)
+
+	status, body = do(http.MethodGet, "/api/export", "", nil, http.Header{"Accept": {"text/csv"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, strings.HasPrefix(body, "id,original_url,user_id,expires_at,is_deleted\n"), body)
+	assert.Contains(t, body, "\nfirst,https://example.com/1,,,\n")
+
+	status, _ = do(http.MethodGet, "/api/export?scope=all", "", nil, nil)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, body = do(http.MethodGet, "/api/export?scope=all", "", nil, http.Header{AdminTokenHeader: {"secret"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, strings.Split(strings.TrimSpace(body), "\n"), 7)
+
+	// администратор восстанавливает выгрузку в пустое хранилище без потерь
+	restored := storage.InitMemoryStore()
+	rs := httptest.NewServer(RootRouter(restored, "http://localhost:8080", WithAdminToken("secret")))
+	defer rs.Close()
+	req, err := http.NewRequest(http.MethodPost, rs.URL+"/api/import", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set(AdminTokenHeader, "secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	report = importReport{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 7, report.Created)
+	n, err := restored.CountURLs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 7, n)
+	records, err := restored.GetUserURLs(context.Background(), "someone")
+	require.NoError(t, err)
+	assert.Empty(t, records)
+	_, err = restored.GetURL(context.Background(), "gone")
+	assert.ErrorIs(t, err, storage.ErrDeleted, "deleted link must stay deleted")
+	_, err = restored.GetURL(context.Background(), "old")
+	assert.ErrorIs(t, err, storage.ErrExpired, "expired link must stay expired")
+	record, err := restored.GetRecord(context.Background(), "old")
+	require.NoError(t, err)
+	require.NotNil(t, record.ExpiresAt)
+	assert.True(t, expired.Equal(*record.ExpiresAt))
+
+	// обычный пользователь не может загрузить уже просроченную ссылку
+	status, body = do(http.MethodPost, "/api/import", "application/x-ndjson",
+		strings.NewReader(`{"original_url":"https://example.com/8","expires_at":"2000-01-01T00:00:00Z"}`), nil)
+	require.Equal(t, http.StatusOK, status, body)
+	report = importReport{}
+	require.NoError(t, json.Unmarshal([]byte(body), &report))
+	assert.Equal(t, 1, report.Invalid)
+}
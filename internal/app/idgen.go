@@ -0,0 +1,108 @@
+package app
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	IDGeneratorHash    = "hash"
+	IDGeneratorRandom  = "random"
+	IDGeneratorCounter = "counter"
+
+	base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+// IDGenerator предлагает идентификатор для ссылки. attempt — номер попытки,
+// начиная с нуля: при коллизии Shortener запрашивает следующий вариант.
+type IDGenerator interface {
+	Generate(originalURL string, attempt int) (string, error)
+}
+
+// HashIDGenerator выводит идентификатор из SHA-1 ссылки, поэтому одна и та же
+// ссылка на первой попытке всегда получает один и тот же идентификатор.
+// При коллизии к ссылке добавляется номер попытки.
+type HashIDGenerator struct{}
+
+func (HashIDGenerator) Generate(originalURL string, attempt int) (string, error) {
+	if attempt == 0 {
+		return GenerateShortURLID(originalURL), nil
+	}
+	return GenerateShortURLID(originalURL + "#" + strconv.Itoa(attempt)), nil
+}
+
+// RandomIDGenerator выдаёт случайные base62-строки заданной длины.
+type RandomIDGenerator struct {
+	Length int
+}
+
+func (g RandomIDGenerator) Generate(string, int) (string, error) {
+	id := make([]byte, 0, g.Length)
+	buf := make([]byte, g.Length)
+	for len(id) < g.Length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			// отбрасываем значения за пределами 62*4, чтобы не смещать распределение
+			if b >= 248 {
+				continue
+			}
+			id = append(id, base62Alphabet[b%62])
+			if len(id) == g.Length {
+				break
+			}
+		}
+	}
+	return string(id), nil
+}
+
+// CounterIDGenerator кодирует в base62 монотонно растущий счётчик.
+type CounterIDGenerator struct {
+	next atomic.Uint64
+}
+
+func NewCounterIDGenerator(start uint64) *CounterIDGenerator {
+	g := &CounterIDGenerator{}
+	g.next.Store(start)
+	return g
+}
+
+func (g *CounterIDGenerator) Generate(string, int) (string, error) {
+	return encodeBase62(g.next.Add(1)), nil
+}
+
+func encodeBase62(n uint64) string {
+	if n == 0 {
+		return base62Alphabet[:1]
+	}
+	var buf [11]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = base62Alphabet[n%62]
+		n /= 62
+	}
+	return string(buf[i:])
+}
+
+// NewIDGenerator создаёт генератор по имени стратегии. Счётчик начинается
+// с текущего времени в миллисекундах, чтобы после перезапуска не повторять
+// уже выданные идентификаторы.
+func NewIDGenerator(kind string, length int) (IDGenerator, error) {
+	switch kind {
+	case IDGeneratorHash:
+		return HashIDGenerator{}, nil
+	case IDGeneratorRandom:
+		if length <= 0 {
+			return nil, fmt.Errorf("invalid random ID length: %d", length)
+		}
+		return RandomIDGenerator{Length: length}, nil
+	case IDGeneratorCounter:
+		return NewCounterIDGenerator(uint64(time.Now().UnixMilli())), nil
+	}
+	return nil, fmt.Errorf("unknown ID generator: %q", kind)
+}
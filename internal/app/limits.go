@@ -0,0 +1,50 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// BodyLimits ограничивает размер запросов. Нулевое значение поля
+// отключает соответствующее ограничение.
+type BodyLimits struct {
+	// MaxBodyBytes — размер тела запроса в том виде, в каком его прислал клиент.
+	MaxBodyBytes int64
+	// MaxDecompressedBytes — размер тела после распаковки gzip.
+	MaxDecompressedBytes int64
+	// MaxBatchItems — число ссылок в пакетном запросе на создание или удаление.
+	MaxBatchItems int
+}
+
+func DefaultBodyLimits() BodyLimits {
+	return BodyLimits{
+		MaxBodyBytes:         1 << 20,
+		MaxDecompressedBytes: 10 << 20,
+		MaxBatchItems:        1000,
+	}
+}
+
+func limitBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		if maxBytes <= 0 {
+			return h
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkBatchSize возвращает ошибку 413, если в пакетном запросе слишком
+// много ссылок.
+func checkBatchSize(items, maxItems int) error {
+	if maxItems <= 0 || items <= maxItems {
+		return nil
+	}
+	return &apiError{
+		Status: http.StatusRequestEntityTooLarge,
+		Code:   errCodeTooLarge,
+		Detail: fmt.Sprintf("batch has %d items, at most %d are allowed", items, maxItems),
+	}
+}
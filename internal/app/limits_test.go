@@ -0,0 +1,73 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestBodyLimits(t *testing.T) {
+	router := RootRouter(storage.InitMemoryStore(), "http://localhost:8080", WithBodyLimits(BodyLimits{
+		MaxBodyBytes:         1024,
+		MaxDecompressedBytes: 4096,
+		MaxBatchItems:        2,
+	}))
+
+	// бомба: 256 КБ пробелов сжимаются в полкилобайта
+	bomb := gzipped(t, append([]byte(`{"url": "https://example.com/"`), bytes.Repeat([]byte(" "), 1<<18)...))
+	require.Less(t, len(bomb), 1024)
+
+	testCases := []struct {
+		name         string
+		path         string
+		body         []byte
+		gzip         bool
+		expectedCode int
+	}{
+		{name: "Small body", path: "/api/shorten", body: []byte(`{"url": "https://example.com/a"}`), expectedCode: http.StatusCreated},
+		{name: "Raw body too large", path: "/", body: []byte("https://example.com/" + strings.Repeat("a", 2048)), expectedCode: http.StatusRequestEntityTooLarge},
+		{name: "Compressed body fits", path: "/api/shorten", body: gzipped(t, []byte(`{"url": "https://example.com/b"}`)), gzip: true, expectedCode: http.StatusCreated},
+		{name: "Gzip bomb", path: "/api/shorten", body: bomb, gzip: true, expectedCode: http.StatusRequestEntityTooLarge},
+		{name: "Not gzip", path: "/api/shorten", body: []byte(`{"url": "https://example.com/c"}`), gzip: true, expectedCode: http.StatusBadRequest},
+		{name: "Batch within limit", path: "/api/shorten/batch", body: []byte(`[{"correlation_id": "1", "original_url": "https://example.com/1"},
+			{"correlation_id": "2", "original_url": "https://example.com/2"}]`), expectedCode: http.StatusCreated},
+		{name: "Batch too large", path: "/api/shorten/batch", body: []byte(`[{"correlation_id": "1", "original_url": "https://example.com/1"},
+			{"correlation_id": "2", "original_url": "https://example.com/2"}, {"correlation_id": "3", "original_url": "https://example.com/3"}]`),
+			expectedCode: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", contentTypeProblem)
+			if tc.gzip {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code, rec.Body.String())
+			if tc.expectedCode == http.StatusRequestEntityTooLarge {
+				assert.Contains(t, rec.Body.String(), `"code":"request_too_large"`)
+			}
+		})
+	}
+}
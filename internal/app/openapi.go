@@ -0,0 +1,350 @@
+package app
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+// openAPIDoc — описание HTTP API. Шаблоны путей в нём совпадают
+// с маршрутами RootRouter.
+//
+//go:embed openapi.json
+var openAPIDoc []byte
+
+var openAPI = mustParseSpec(openAPIDoc)
+
+type apiSpec struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas    map[string]*schema    `json:"schemas"`
+		Parameters map[string]*parameter `json:"parameters"`
+		Responses  map[string]*response  `json:"responses"`
+	} `json:"components"`
+}
+
+type operation struct {
+	Parameters  []*parameter         `json:"parameters"`
+	RequestBody *requestBody         `json:"requestBody"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool    `json:"required"`
+	Content  content `json:"content"`
+}
+
+type response struct {
+	Ref     string  `json:"$ref"`
+	Content content `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// content — описания тела по типам содержимого. Ключом может быть
+// и диапазон типов вроде text/* или */*.
+type content map[string]*mediaType
+
+// lookup находит описание для типа содержимого и возвращает ключ, под
+// которым оно записано.
+func (c content) lookup(mediaType string) (string, *mediaType) {
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, key := range []string{mediaType, major + "/*", "*/*"} {
+		if m, ok := c[key]; ok {
+			return key, m
+		}
+	}
+	return "", nil
+}
+
+func (c content) types() string {
+	types := make([]string, 0, len(c))
+	for key := range c {
+		types = append(types, key)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func mustParseSpec(data []byte) *apiSpec {
+	spec, err := parseSpec(data)
+	if err != nil {
+		panic(fmt.Sprintf("openapi.json: %v", err))
+	}
+	return spec
+}
+
+// parseSpec разбирает документ и подставляет ссылки $ref на components.
+func parseSpec(data []byte) (*apiSpec, error) {
+	var spec apiSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	r := specResolver{spec: &spec, done: make(map[*schema]bool)}
+	for path, item := range spec.Paths {
+		for method, op := range item {
+			if err := r.operation(op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+		}
+	}
+	return &spec, nil
+}
+
+type specResolver struct {
+	spec *apiSpec
+	done map[*schema]bool
+}
+
+func refName(ref, prefix string) (string, error) {
+	name, ok := strings.CutPrefix(ref, prefix)
+	if !ok {
+		return "", fmt.Errorf("unsupported reference %q", ref)
+	}
+	return name, nil
+}
+
+func (r specResolver) operation(op *operation) error {
+	for i, p := range op.Parameters {
+		if p.Ref != "" {
+			name, err := refName(p.Ref, "#/components/parameters/")
+			if err != nil {
+				return err
+			}
+			if p = r.spec.Components.Parameters[name]; p == nil {
+				return fmt.Errorf("unknown parameter %q", name)
+			}
+			op.Parameters[i] = p
+		}
+		if err := r.schema(&p.Schema); err != nil {
+			return err
+		}
+	}
+	if op.RequestBody != nil {
+		if err := r.content(op.RequestBody.Content); err != nil {
+			return err
+		}
+	}
+	for status, resp := range op.Responses {
+		if resp.Ref != "" {
+			name, err := refName(resp.Ref, "#/components/responses/")
+			if err != nil {
+				return err
+			}
+			if resp = r.spec.Components.Responses[name]; resp == nil {
+				return fmt.Errorf("unknown response %q", name)
+			}
+			op.Responses[status] = resp
+		}
+		if err := r.content(resp.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r specResolver) content(c content) error {
+	for _, m := range c {
+		if err := r.schema(&m.Schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r specResolver) schema(s **schema) error {
+	if *s == nil {
+		return nil
+	}
+	if ref := (*s).Ref; ref != "" {
+		name, err := refName(ref, "#/components/schemas/")
+		if err != nil {
+			return err
+		}
+		target := r.spec.Components.Schemas[name]
+		if target == nil {
+			return fmt.Errorf("unknown schema %q", name)
+		}
+		*s = target
+	}
+	if r.done[*s] {
+		return nil
+	}
+	r.done[*s] = true
+	for name := range (*s).Properties {
+		prop := (*s).Properties[name]
+		if err := r.schema(&prop); err != nil {
+			return err
+		}
+		(*s).Properties[name] = prop
+	}
+	return r.schema(&(*s).Items)
+}
+
+// find возвращает описание операции, которую chi выберет для запроса.
+func (spec *apiSpec) find(routes chi.Routes, r *http.Request) *operation {
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+	rctx := chi.NewRouteContext()
+	if !routes.Match(rctx, r.Method, path) {
+		return nil
+	}
+	return spec.Paths[rctx.RoutePattern()][strings.ToLower(r.Method)]
+}
+
+func handleOpenAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(openAPIDoc); err != nil {
+			logger.Log.Debug("error writing response", zap.Error(err))
+		}
+	}
+}
+
+// validateRequests отклоняет запросы, параметры или тело которых не
+// соответствуют описанию API. Тела проверяются только в JSON, остальные
+// форматы разбирают сами обработчики. Запросы к неописанным маршрутам
+// пропускаются без проверки.
+func validateRequests(spec *apiSpec, routes chi.Routes) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op := spec.find(routes, r)
+			if op == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if errs := op.checkQuery(r.URL.Query()); len(errs) > 0 {
+				e := invalidRequest("query parameters do not match the API schema")
+				e.Errors = errs
+				writeError(w, e)
+				return
+			}
+			if op.RequestBody != nil && !op.RequestBody.check(w, r) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (op *operation) checkQuery(query url.Values) []fieldError {
+	var errs []fieldError
+	for _, p := range op.Parameters {
+		if p.In != "query" {
+			continue
+		}
+		values, ok := query[p.Name]
+		if !ok {
+			if p.Required {
+				errs = append(errs, fieldError{Parameter: p.Name, Detail: "is required"})
+			}
+			continue
+		}
+		if p.Schema == nil {
+			continue
+		}
+		for _, value := range values {
+			// параметры приходят строками, числа сравниваются как JSON
+			var v any = value
+			if p.Schema.Type == "integer" || p.Schema.Type == "number" {
+				v = json.Number(value)
+			}
+			for _, e := range p.Schema.validate(v, "") {
+				errs = append(errs, fieldError{Parameter: p.Name, Detail: e.Detail})
+			}
+		}
+	}
+	return errs
+}
+
+// check проверяет тело запроса и возвращает false, если ответ уже
+// отправлен. Прочитанное тело подставляется в запрос заново.
+func (body *requestBody) check(w http.ResponseWriter, r *http.Request) bool {
+	var media *mediaType
+	if header := r.Header.Get("Content-Type"); header == "" {
+		// без Content-Type обработчики разбирают тело как JSON
+		media = body.Content["application/json"]
+	} else {
+		mediaType, _, err := mime.ParseMediaType(header)
+		var key string
+		if err == nil {
+			key, media = body.Content.lookup(mediaType)
+		}
+		if media == nil {
+			writeError(w, &apiError{
+				Status: http.StatusUnsupportedMediaType,
+				Code:   errCodeMediaType,
+				Detail: fmt.Sprintf("Content-Type %q is not supported, use one of: %s", header, body.Content.types()),
+			})
+			return false
+		}
+		if !isJSONMediaType(key) {
+			return true
+		}
+	}
+	if media == nil || media.Schema == nil {
+		return true
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, bodyError(err))
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	invalid := func(detail string, errs []fieldError) bool {
+		e := invalidRequest("%s", detail)
+		e.Errors = errs
+		writeError(w, e)
+		return false
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		if body.Required {
+			return invalid("request body is required", nil)
+		}
+		return true
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return invalid("request body is not valid JSON: "+err.Error(), nil)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return invalid("request body must contain a single JSON value", nil)
+	}
+	if errs := media.Schema.validate(v, ""); len(errs) > 0 {
+		return invalid("request body does not match the API schema", errs)
+	}
+	return true
+}
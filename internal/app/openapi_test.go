@@ -0,0 +1,255 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIDocumentsRoutes(t *testing.T) {
+	router := RootRouter(storage.InitMemoryStore(), "http://localhost:8080")
+
+	registered := make(map[string]bool)
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		key := method + " " + route
+		registered[key] = true
+		assert.NotNil(t, openAPI.Paths[route][strings.ToLower(method)], "route %s is not documented", key)
+		return nil
+	})
+	require.NoError(t, err)
+
+	for path, item := range openAPI.Paths {
+		for method := range item {
+			key := strings.ToUpper(method) + " " + path
+			assert.True(t, registered[key], "documented operation %s is not routed", key)
+		}
+	}
+}
+
+// checkResponse проверяет ответ по описанию операции в документе.
+func checkResponse(t *testing.T, method, path string, resp *http.Response, body []byte) {
+	t.Helper()
+	op := openAPI.Paths[path][strings.ToLower(method)]
+	require.NotNil(t, op, "%s %s is not documented", method, path)
+	documented, ok := op.Responses[strconv.Itoa(resp.StatusCode)]
+	require.True(t, ok, "%s %s: status %d is not documented", method, path, resp.StatusCode)
+	if len(body) == 0 {
+		return
+	}
+	contentType := resp.Header.Get("Content-Type")
+	key, media := documented.Content.lookup(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	require.NotNil(t, media, "%s %s: content type %q is not documented", method, path, contentType)
+	if isJSONMediaType(key) {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var v any
+		require.NoError(t, dec.Decode(&v))
+		assert.Empty(t, media.Schema.validate(v, ""), "%s %s: %s", method, path, body)
+	}
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	ts := httptest.NewServer(RootRouter(storage.InitMemoryStore(), "http://localhost:8080"))
+	defer ts.Close()
+
+	var token string
+	do := func(method, path, pattern, contentType, body string) []byte {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if token == "" {
+			token = resp.Header.Get("Authorization")
+		}
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		checkResponse(t, method, pattern, resp, data)
+		return data
+	}
+
+	doc := do(http.MethodGet, "/api/openapi.json", "/api/openapi.json", "", "")
+	assert.JSONEq(t, string(openAPIDoc), string(doc))
+
+	do(http.MethodPost, "/api/shorten", "/api/shorten", "application/json", `{"url": "https://example.com/a"}`)
+	do(http.MethodPost, "/api/shorten", "/api/shorten", "application/json", `{"url": "https://example.com/b", "alias": "promo"}`)
+	do(http.MethodPost, "/api/shorten", "/api/shorten", "application/json", `{"url": "https://example.com/c", "alias": "promo"}`)
+	do(http.MethodPost, "/api/shorten", "/api/shorten", "application/json", `{"url": "ftp://example.com/"}`)
+	do(http.MethodPost, "/", "/", "text/plain", "https://example.com/d")
+	do(http.MethodPost, "/", "/", "text/plain", "javascript:alert(1)")
+	do(http.MethodPost, "/api/shorten/batch", "/api/shorten/batch", "application/json",
+		`[{"correlation_id": "1", "original_url": "https://example.com/e"}]`)
+	do(http.MethodGet, "/api/user/urls", "/api/user/urls", "", "")
+	do(http.MethodGet, "/api/stats/promo", "/api/stats/{id}", "", "")
+	do(http.MethodGet, "/promo", "/{id}", "", "")
+	do(http.MethodGet, "/missing", "/{id}", "", "")
+	do(http.MethodGet, "/api/stats/missing", "/api/stats/{id}", "", "")
+	do(http.MethodGet, "/api/export", "/api/export", "", "")
+	do(http.MethodPost, "/api/import", "/api/import", "application/x-ndjson", `{"original_url": "https://example.com/f"}`)
+	do(http.MethodDelete, "/api/user/urls", "/api/user/urls", "application/json", `["promo"]`)
+}
+
+func TestRequestValidation(t *testing.T) {
+	ts := httptest.NewServer(RootRouter(storage.InitMemoryStore(), "http://localhost:8080"))
+	defer ts.Close()
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		contentType  string
+		body         string
+		expectedCode int
+		// expectedErrors — ожидаемые указатели и описания ошибок
+		expectedErrors []fieldError
+	}{
+		{
+			name:         "Valid request",
+			path:         "/api/shorten",
+			contentType:  "application/json",
+			body:         `{"url": "https://example.com/ok", "ttl_seconds": 60}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Valid request without Content-Type",
+			path:         "/api/shorten",
+			body:         `{"url": "https://example.com/no-type"}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:           "Unknown field",
+			path:           "/api/shorten",
+			contentType:    "application/json",
+			body:           `{"url": "https://example.com/", "URL": "https://example.com/"}`,
+			expectedCode:   http.StatusBadRequest,
+			expectedErrors: []fieldError{{Pointer: "/URL", Detail: "unknown field"}},
+		},
+		{
+			name:           "Empty URL",
+			path:           "/api/shorten",
+			contentType:    "application/json",
+			body:           `{"url": ""}`,
+			expectedCode:   http.StatusBadRequest,
+			expectedErrors: []fieldError{{Pointer: "/url", Detail: "must not be empty"}},
+		},
+		{
+			name:         "Missing URL and wrong types",
+			path:         "/api/shorten",
+			contentType:  "application/json",
+			body:         `{"ttl_seconds": "60", "expires_at": "tomorrow"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedErrors: []fieldError{
+				{Pointer: "/url", Detail: "is required"},
+				{Pointer: "/expires_at", Detail: "must be an RFC 3339 date-time"},
+				{Pointer: "/ttl_seconds", Detail: "must be a number"},
+			},
+		},
+		{
+			name:         "Not JSON",
+			path:         "/api/shorten",
+			contentType:  "application/json",
+			body:         `{"url": `,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Trailing data",
+			path:         "/api/shorten",
+			contentType:  "application/json",
+			body:         `{"url": "https://example.com/"} {}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Unsupported Content-Type",
+			path:         "/api/shorten",
+			contentType:  "text/plain",
+			body:         `{"url": "https://example.com/"}`,
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "Empty batch",
+			path:           "/api/shorten/batch",
+			contentType:    "application/json",
+			body:           `[]`,
+			expectedCode:   http.StatusBadRequest,
+			expectedErrors: []fieldError{{Pointer: "", Detail: "must have at least 1 items"}},
+		},
+		{
+			name:           "Batch item without correlation ID",
+			path:           "/api/shorten/batch",
+			contentType:    "application/json",
+			body:           `[{"correlation_id": "1", "original_url": "https://example.com/1"}, {"original_url": "https://example.com/2"}]`,
+			expectedCode:   http.StatusBadRequest,
+			expectedErrors: []fieldError{{Pointer: "/1/correlation_id", Detail: "is required"}},
+		},
+		{
+			name:           "Delete with numbers",
+			method:         http.MethodDelete,
+			path:           "/api/user/urls",
+			contentType:    "application/json",
+			body:           `["abc", 1]`,
+			expectedCode:   http.StatusBadRequest,
+			expectedErrors: []fieldError{{Pointer: "/1", Detail: "must be a string"}},
+		},
+		{
+			name:           "Unknown export scope",
+			method:         http.MethodGet,
+			path:           "/api/export?scope=everything",
+			expectedCode:   http.StatusBadRequest,
+			expectedErrors: []fieldError{{Parameter: "scope", Detail: "must be one of [all]"}},
+		},
+		{
+			name:         "Text endpoint accepts any Content-Type",
+			path:         "/",
+			contentType:  "application/x-www-form-urlencoded",
+			body:         "https://example.com/form",
+			expectedCode: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req, err := http.NewRequest(method, ts.URL+tc.path, strings.NewReader(tc.body))
+			require.NoError(t, err)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			if tc.expectedCode < http.StatusBadRequest {
+				return
+			}
+			assert.Equal(t, contentTypeProblem, resp.Header.Get("Content-Type"))
+			var p problem
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+			assert.Equal(t, tc.expectedCode, p.Status)
+			assert.NotEmpty(t, p.Detail)
+			if tc.expectedErrors != nil {
+				assert.Equal(t, tc.expectedErrors, p.Errors)
+			}
+		})
+	}
+}
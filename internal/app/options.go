@@ -0,0 +1,148 @@
+package app
+
+import (
+	"github.com/ma-shulgin/go-link-shortener/internal/analytics"
+	"github.com/ma-shulgin/go-link-shortener/internal/auth"
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+)
+
+type options struct {
+	signer      *auth.Signer
+	deleter     *URLDeleter
+	aliases     *AliasPolicy
+	idGenerator IDGenerator
+	clicks      analytics.Store
+	ipSalt      string
+	pipeline    *analytics.Pipeline
+	adminToken  string
+	urls        *URLPolicy
+	domains     *DomainPolicy
+	limiter     *RateLimiter
+	limits      *BodyLimits
+}
+
+// Option настраивает необязательные зависимости RootRouter и NewGRPCServer.
+type Option func(*options)
+
+// newOptions применяет opts и подставляет значения по умолчанию для
+// зависимостей, общих для HTTP и gRPC.
+func newOptions(urlStorage storage.URLStore, opts []Option) *options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.signer == nil {
+		signer, err := auth.NewRandomSigner()
+		if err != nil {
+			logger.Log.Fatal("Failed to generate auth key: ", err)
+		}
+		o.signer = signer
+	}
+	if o.deleter == nil {
+		o.deleter = NewURLDeleter(urlStorage)
+	}
+	if o.idGenerator == nil {
+		o.idGenerator = HashIDGenerator{}
+	}
+	if o.aliases == nil {
+		aliases := DefaultAliasPolicy()
+		o.aliases = &aliases
+	}
+	if o.urls == nil {
+		urls := DefaultURLPolicy()
+		o.urls = &urls
+	}
+	if o.limits == nil {
+		limits := DefaultBodyLimits()
+		o.limits = &limits
+	}
+	return &o
+}
+
+// WithSigner задаёт ключ подписи пользовательских токенов. Без него
+// RootRouter использует случайный ключ, живущий до перезапуска.
+func WithSigner(signer *auth.Signer) Option {
+	return func(o *options) {
+		o.signer = signer
+	}
+}
+
+// WithDeleter задаёт фоновый обработчик удаления ссылок. Владелец должен
+// вызвать его Close при остановке сервиса, чтобы дописать очередь.
+func WithDeleter(deleter *URLDeleter) Option {
+	return func(o *options) {
+		o.deleter = deleter
+	}
+}
+
+// WithAliasPolicy задаёт правила проверки пользовательских идентификаторов.
+func WithAliasPolicy(policy AliasPolicy) Option {
+	return func(o *options) {
+		o.aliases = &policy
+	}
+}
+
+// WithIDGenerator задаёт стратегию генерации идентификаторов.
+// По умолчанию используется HashIDGenerator.
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(o *options) {
+		o.idGenerator = gen
+	}
+}
+
+// WithAnalytics задаёт хранилище переходов по ссылкам. Адреса клиентов
+// хешируются вместе с ipSalt. По умолчанию переходы хранятся в памяти.
+func WithAnalytics(clicks analytics.Store, ipSalt string) Option {
+	return func(o *options) {
+		o.clicks = clicks
+		o.ipSalt = ipSalt
+	}
+}
+
+// WithClickPipeline задаёт конвейер асинхронной записи переходов. Владелец
+// должен вызвать его Close при остановке сервиса.
+func WithClickPipeline(pipeline *analytics.Pipeline) Option {
+	return func(o *options) {
+		o.pipeline = pipeline
+	}
+}
+
+// WithAdminToken задаёт токен администратора для заголовка X-Admin-Token.
+// Без него выгрузка всех ссылок недоступна.
+func WithAdminToken(token string) Option {
+	return func(o *options) {
+		o.adminToken = token
+	}
+}
+
+// WithURLPolicy задаёт правила проверки и нормализации сокращаемых ссылок.
+func WithURLPolicy(policy URLPolicy) Option {
+	return func(o *options) {
+		o.urls = &policy
+	}
+}
+
+// WithDomainPolicy задаёт списки разрешённых и запрещённых доменов. Они
+// проверяются при сокращении ссылки и повторно при переходе по ней.
+func WithDomainPolicy(domains *DomainPolicy) Option {
+	return func(o *options) {
+		o.domains = domains
+	}
+}
+
+// WithRateLimiter ограничивает частоту создания ссылок и переходов по ним
+// для каждого клиента. По умолчанию ограничений нет.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(o *options) {
+		o.limiter = limiter
+	}
+}
+
+// WithBodyLimits задаёт ограничения размера запросов.
+// По умолчанию используются DefaultBodyLimits.
+func WithBodyLimits(limits BodyLimits) Option {
+	return func(o *options) {
+		o.limits = &limits
+	}
+}
@@ -0,0 +1,289 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/auth"
+	pb "github.com/ma-shulgin/go-link-shortener/pkg/shortenerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRateLimitIdle — время, после которого неиспользуемое ведро
+// удаляется. Удалённое ведро при следующем запросе создаётся полным.
+const DefaultRateLimitIdle = 10 * time.Minute
+
+// RateLimit — параметры ведра токенов: Rate запросов в секунду в среднем
+// и не больше Burst подряд. Нулевой Rate отключает ограничение.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type RateLimitConfig struct {
+	// Create ограничивает создание ссылок, Redirect — переходы по ним.
+	Create   RateLimit
+	Redirect RateLimit
+	// TrustedProxies — адреса и подсети прокси, которым разрешено
+	// передавать адрес клиента в X-Forwarded-For.
+	TrustedProxies []string
+	// IdleTimeout задаёт, через сколько удаляются неиспользуемые вёдра.
+	IdleTimeout time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// bucketSet хранит вёдра одной группы маршрутов.
+type bucketSet struct {
+	limit RateLimit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// take забирает по токену из каждого ведра keys. Запрос проходит, только
+// если токен нашёлся во всех вёдрах, иначе ни одно не расходуется.
+// Возвращает наименьший остаток и, если токенов не хватило, время до
+// появления недостающих.
+func (s *bucketSet) take(keys []string, now time.Time, idle time.Duration) (float64, time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= idle {
+		for k, b := range s.buckets {
+			if now.Sub(b.last) >= idle {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	buckets := make([]*bucket, len(keys))
+	tokens := float64(s.limit.Burst)
+	for i, key := range keys {
+		b, ok := s.buckets[key]
+		if !ok {
+			b = &bucket{tokens: float64(s.limit.Burst), last: now}
+			s.buckets[key] = b
+		}
+		b.tokens = math.Min(float64(s.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*s.limit.Rate)
+		b.last = now
+		buckets[i] = b
+		tokens = math.Min(tokens, b.tokens)
+	}
+
+	if tokens < 1 {
+		wait := time.Duration((1 - tokens) / s.limit.Rate * float64(time.Second))
+		return tokens, wait, false
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return tokens - 1, 0, true
+}
+
+func (s *bucketSet) size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
+
+// RateLimiter ограничивает частоту запросов каждого клиента. Каждый запрос
+// расходует ведро IP-адреса клиента с учётом X-Forwarded-For от доверенных
+// прокси, а запрос с действительным токеном — ещё и ведро пользователя.
+// Поэтому новые токены не дают обойти ограничение по адресу.
+type RateLimiter struct {
+	create   *bucketSet
+	redirect *bucketSet
+	proxies  []*net.IPNet
+	idle     time.Duration
+	now      func() time.Time
+}
+
+func NewRateLimiter(cfg RateLimitConfig) (*RateLimiter, error) {
+	l := &RateLimiter{idle: cfg.IdleTimeout, now: time.Now}
+	if l.idle <= 0 {
+		l.idle = DefaultRateLimitIdle
+	}
+	var err error
+	if l.create, err = newBucketSet(cfg.Create); err != nil {
+		return nil, fmt.Errorf("create rate limit: %w", err)
+	}
+	if l.redirect, err = newBucketSet(cfg.Redirect); err != nil {
+		return nil, fmt.Errorf("redirect rate limit: %w", err)
+	}
+	for _, proxy := range cfg.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", proxy, err)
+		}
+		l.proxies = append(l.proxies, network)
+	}
+	return l, nil
+}
+
+func newBucketSet(limit RateLimit) (*bucketSet, error) {
+	if limit.Rate < 0 {
+		return nil, fmt.Errorf("rate must not be negative: %v", limit.Rate)
+	}
+	if limit.Rate == 0 {
+		return nil, nil
+	}
+	if limit.Burst < 1 {
+		return nil, fmt.Errorf("burst must be positive with non-zero rate: %d", limit.Burst)
+	}
+	return &bucketSet{limit: limit, buckets: make(map[string]*bucket)}, nil
+}
+
+func (l *RateLimiter) trusted(ip net.IP) bool {
+	for _, network := range l.proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientKeys возвращает вёдра, которые расходует запрос.
+func (l *RateLimiter) clientKeys(r *http.Request) []string {
+	return l.keys(r.Context(), clientIP(r), r.Header.Values("X-Forwarded-For"))
+}
+
+// remoteIP возвращает адрес клиента с учётом доверенных прокси. Без
+// RateLimiter доверенных прокси нет, и берётся адрес соединения.
+func (l *RateLimiter) remoteIP(r *http.Request) string {
+	if l == nil {
+		return clientIP(r)
+	}
+	return l.clientAddr(clientIP(r), r.Header.Values("X-Forwarded-For"))
+}
+
+// keys возвращает ведро адреса клиента и, если запрос с действительным
+// токеном, ведро пользователя.
+func (l *RateLimiter) keys(ctx context.Context, addr string, forwarded []string) []string {
+	keys := []string{"ip:" + l.clientAddr(addr, forwarded)}
+	if auth.IsAuthenticated(ctx) {
+		keys = append(keys, "user:"+auth.UserIDFromContext(ctx))
+	}
+	return keys
+}
+
+// clientAddr возвращает IP-адрес клиента. X-Forwarded-For просматривается
+// справа налево: первый адрес, не принадлежащий доверенному прокси,
+// считается адресом клиента. Левее него значения подделываются клиентом
+// без труда.
+func (l *RateLimiter) clientAddr(addr string, forwarded []string) string {
+	ip := net.ParseIP(addr)
+	if ip != nil && l.trusted(ip) {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := net.ParseIP(strings.TrimSpace(hops[i]))
+			if hop == nil {
+				break
+			}
+			addr = hop.String()
+			if !l.trusted(hop) {
+				break
+			}
+		}
+	}
+	return addr
+}
+
+// CreateMiddleware ограничивает маршруты создания ссылок.
+// Нулевой указатель на RateLimiter ничего не ограничивает.
+func (l *RateLimiter) CreateMiddleware(h http.Handler) http.Handler {
+	if l == nil {
+		return h
+	}
+	return l.middleware(l.create, h)
+}
+
+// RedirectMiddleware ограничивает переходы по коротким ссылкам.
+func (l *RateLimiter) RedirectMiddleware(h http.Handler) http.Handler {
+	if l == nil {
+		return h
+	}
+	return l.middleware(l.redirect, h)
+}
+
+func (l *RateLimiter) middleware(set *bucketSet, h http.Handler) http.Handler {
+	if set == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens, wait, ok := set.take(l.clientKeys(r), l.now(), l.idle)
+
+		limit := set.limit
+		full := time.Duration((float64(limit.Burst) - tokens) / limit.Rate * float64(time.Second))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(int(tokens)))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(full)))
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(wait)))
+			writeError(w, &apiError{
+				Status: http.StatusTooManyRequests,
+				Code:   errCodeRateLimited,
+				Detail: "too many requests, retry later",
+			})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// UnaryServerInterceptor ограничивает вызовы gRPC теми же вёдрами, что и
+// HTTP: Shorten и ShortenBatch расходуют ведро создания, Resolve — ведро
+// переходов. Адрес клиента берётся из соединения и x-forwarded-for.
+func (l *RateLimiter) UnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var set *bucketSet
+	switch info.FullMethod {
+	case pb.Shortener_Shorten_FullMethodName, pb.Shortener_ShortenBatch_FullMethodName:
+		set = l.create
+	case pb.Shortener_Resolve_FullMethodName:
+		set = l.redirect
+	}
+	if set == nil {
+		return handler(ctx, req)
+	}
+
+	var addr string
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if _, wait, ok := set.take(l.keys(ctx, addr, md.Get("x-forwarded-for")), l.now(), l.idle); !ok {
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(ceilSeconds(wait))))
+		return nil, status.Error(codes.ResourceExhausted, "too many requests, retry later")
+	}
+	return handler(ctx, req)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitConfig{
+		Create:      RateLimit{Rate: 1, Burst: 2},
+		Redirect:    RateLimit{Rate: 10, Burst: 10},
+		IdleTimeout: time.Minute,
+	})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	router := RootRouter(storage.InitMemoryStore(), "http://localhost:8080", WithRateLimiter(limiter))
+	// каждая ссылка новая: повторное сокращение отвечает 409
+	var n int
+	shorten := func(remoteAddr string) *httptest.ResponseRecorder {
+		n++
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fmt.Sprintf("https://example.com/%d", n)))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		rec := shorten("192.0.2.1:1000")
+		assert.Less(t, rec.Code, 300)
+		assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	}
+	rec := shorten("192.0.2.1:1001")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Reset"))
+	assert.JSONEq(t, `{"type": "about:blank", "title": "Too Many Requests", "status": 429,
+		"detail": "too many requests, retry later", "code": "rate_limited"}`, rec.Body.String())
+
+	// у другого клиента своё ведро, а переходы ограничиваются отдельно
+	assert.Less(t, shorten("192.0.2.2:1000").Code, 300)
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	req.RemoteAddr = "192.0.2.1:1000"
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.NotEqual(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get("RateLimit-Limit"))
+
+	now = now.Add(time.Second)
+	assert.Less(t, shorten("192.0.2.1:1000").Code, 300)
+
+	// неиспользуемые вёдра удаляются
+	assert.Equal(t, 2, limiter.create.size())
+	now = now.Add(2 * time.Minute)
+	shorten("192.0.2.3:1000")
+	assert.Equal(t, 1, limiter.create.size())
+}
+
+func TestRateLimiterTokenRotation(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitConfig{Create: RateLimit{Rate: 1, Burst: 2}})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	router := RootRouter(storage.InitMemoryStore(), "http://localhost:8080", WithRateLimiter(limiter))
+	var n int
+	serve := func(method, path, remoteAddr, token string) *httptest.ResponseRecorder {
+		n++
+		req := httptest.NewRequest(method, path, strings.NewReader(fmt.Sprintf("https://example.com/%d", n)))
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// свежие токены не дают новых вёдер: адрес клиента расходуется всегда
+	var tokens []string
+	for i := 0; i < 3; i++ {
+		tokens = append(tokens, serve(http.MethodGet, "/ping", "192.0.2.1:1000", "").Header().Get("Authorization"))
+	}
+	assert.Less(t, serve(http.MethodPost, "/", "192.0.2.1:1000", tokens[0]).Code, 300)
+	assert.Less(t, serve(http.MethodPost, "/", "192.0.2.1:1000", tokens[1]).Code, 300)
+	assert.Equal(t, http.StatusTooManyRequests, serve(http.MethodPost, "/", "192.0.2.1:1000", tokens[2]).Code)
+
+	// ведро пользователя сужает ограничение и при смене адреса
+	now = now.Add(time.Minute)
+	assert.Less(t, serve(http.MethodPost, "/", "192.0.2.2:1000", tokens[0]).Code, 300)
+	assert.Less(t, serve(http.MethodPost, "/", "192.0.2.3:1000", tokens[0]).Code, 300)
+	assert.Equal(t, http.StatusTooManyRequests, serve(http.MethodPost, "/", "192.0.2.4:1000", tokens[0]).Code)
+}
+
+func TestRateLimiterClientKey(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitConfig{TrustedProxies: []string{"10.0.0.0/8", "2001:db8::1"}})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		expectedKey  string
+	}{
+		{name: "Direct client", remoteAddr: "192.0.2.1:1000", expectedKey: "ip:192.0.2.1"},
+		{name: "Untrusted proxy", remoteAddr: "192.0.2.1:1000", forwardedFor: []string{"198.51.100.7"}, expectedKey: "ip:192.0.2.1"},
+		{name: "Trusted proxy", remoteAddr: "10.1.2.3:1000", forwardedFor: []string{"198.51.100.7"}, expectedKey: "ip:198.51.100.7"},
+		{name: "Spoofed hops", remoteAddr: "10.1.2.3:1000", forwardedFor: []string{"203.0.113.9, 198.51.100.7", "10.0.0.2"}, expectedKey: "ip:198.51.100.7"},
+		{name: "Garbage hop", remoteAddr: "10.1.2.3:1000", forwardedFor: []string{"nonsense"}, expectedKey: "ip:10.1.2.3"},
+		{name: "IPv6 proxy", remoteAddr: "[2001:db8::1]:1000", forwardedFor: []string{"2001:db8::42"}, expectedKey: "ip:2001:db8::42"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for _, value := range tc.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			assert.Equal(t, []string{tc.expectedKey}, limiter.clientKeys(req))
+		})
+	}
+
+	_, err = NewRateLimiter(RateLimitConfig{TrustedProxies: []string{"proxy.local"}})
+	assert.Error(t, err)
+	_, err = NewRateLimiter(RateLimitConfig{Create: RateLimit{Rate: 1}})
+	assert.Error(t, err)
+}
+
+func TestNewClickBehindProxy(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitConfig{TrustedProxies: []string{"10.0.0.0/8"}})
+	require.NoError(t, err)
+
+	click := func(limiter *RateLimiter, forwardedFor string) string {
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.RemoteAddr = "10.1.2.3:1000"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		return newClick(req, "abc", "salt", limiter).IPHash
+	}
+
+	// за доверенным прокси посетители различаются по X-Forwarded-For
+	assert.NotEqual(t, click(limiter, "198.51.100.7"), click(limiter, "198.51.100.8"))
+	assert.Equal(t, click(limiter, "198.51.100.7"), click(limiter, "203.0.113.9, 198.51.100.7"))
+	// без RateLimiter доверенных прокси нет
+	assert.Equal(t, click(nil, "198.51.100.7"), click(nil, "198.51.100.8"))
+}
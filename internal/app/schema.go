@@ -0,0 +1,143 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// schema — подмножество JSON Schema из OpenAPI 3.0, которого достаточно
+// для описания API сервиса.
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []any              `json:"enum"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	Minimum              *float64           `json:"minimum"`
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// validate проверяет значение, разобранное json.Decoder с UseNumber,
+// и возвращает все найденные ошибки. pointer — JSON Pointer на значение.
+func (s *schema) validate(v any, pointer string) []fieldError {
+	fail := func(format string, args ...any) []fieldError {
+		return []fieldError{{Pointer: pointer, Detail: fmt.Sprintf(format, args...)}}
+	}
+
+	var errs []fieldError
+	switch s.Type {
+	case "":
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fail("must be an object")
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, fieldError{Pointer: pointer + "/" + pointerEscaper.Replace(name), Detail: "is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fieldPointer := pointer + "/" + pointerEscaper.Replace(name)
+			prop, ok := s.Properties[name]
+			switch {
+			case ok:
+				errs = append(errs, prop.validate(obj[name], fieldPointer)...)
+			case s.AdditionalProperties != nil && !*s.AdditionalProperties:
+				errs = append(errs, fieldError{Pointer: fieldPointer, Detail: "unknown field"})
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fail("must be an array")
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			errs = append(errs, fail("must have at least %d items", *s.MinItems)...)
+		}
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			errs = append(errs, fail("must have at most %d items", *s.MaxItems)...)
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				errs = append(errs, s.Items.validate(item, fmt.Sprintf("%s/%d", pointer, i))...)
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		if s.MinLength != nil && len([]rune(str)) < *s.MinLength {
+			if *s.MinLength == 1 {
+				return fail("must not be empty")
+			}
+			return fail("must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && len([]rune(str)) > *s.MaxLength {
+			return fail("must be at most %d characters long", *s.MaxLength)
+		}
+		if err := checkFormat(s.Format, str); err != nil {
+			return fail("%v", err)
+		}
+	case "integer", "number":
+		num, ok := v.(json.Number)
+		f, err := num.Float64()
+		if !ok || err != nil {
+			return fail("must be a number")
+		}
+		if _, err := num.Int64(); s.Type == "integer" && err != nil {
+			return fail("must be an integer")
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fail("must be at least %v", *s.Minimum)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	default:
+		return fail("has unsupported schema type %q", s.Type)
+	}
+
+	if len(s.Enum) > 0 && len(errs) == 0 {
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				return nil
+			}
+		}
+		return fail("must be one of %v", s.Enum)
+	}
+	return errs
+}
+
+func checkFormat(format, s string) error {
+	switch format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			return errors.New("must be an RFC 3339 date-time")
+		}
+	case "date":
+		if _, err := time.Parse(time.DateOnly, s); err != nil {
+			return errors.New("must be a date in YYYY-MM-DD format")
+		}
+	}
+	return nil
+}
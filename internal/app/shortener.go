@@ -1,8 +1,20 @@
 package app
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+)
+
+const maxIDAttempts = 10
+
+var (
+	ErrAliasTaken  = errors.New("alias is already used for another URL")
+	ErrIDExhausted = errors.New("failed to allocate a free short URL ID")
 )
 
 func GenerateShortURLID(url string) string {
@@ -10,3 +22,181 @@ func GenerateShortURLID(url string) string {
 	hasher.Write([]byte(url))
 	return hex.EncodeToString(hasher.Sum(nil))[:8]
 }
+
+// BatchError указывает, на каком элементе пачки произошла ошибка.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Shortener выделяет ссылкам идентификаторы и сохраняет их в хранилище,
+// не допуская, чтобы идентификатор одной ссылки вернулся для другой.
+type Shortener struct {
+	store storage.URLStore
+	gen   IDGenerator
+}
+
+func NewShortener(store storage.URLStore, gen IDGenerator) *Shortener {
+	return &Shortener{store: store, gen: gen}
+}
+
+type idState int
+
+const (
+	idFree idState = iota
+	idSameURL
+	idTaken
+)
+
+// checkID проверяет, кому принадлежит идентификатор. Удалённые и просроченные
+// ссылки продолжают занимать свой идентификатор.
+func (s *Shortener) checkID(ctx context.Context, id, originalURL string) (idState, error) {
+	existing, err := s.store.GetURL(ctx, id)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return idFree, nil
+	case errors.Is(err, storage.ErrDeleted), errors.Is(err, storage.ErrExpired):
+		return idTaken, nil
+	case err != nil:
+		return idTaken, err
+	case existing == originalURL:
+		return idSameURL, nil
+	}
+	return idTaken, nil
+}
+
+// Shorten сохраняет ссылку и возвращает её идентификатор. Если record.ShortURL
+// задан, он используется как пользовательский alias. Если ссылка уже была
+// сокращена, возвращается её идентификатор вместе с storage.ErrConflict.
+func (s *Shortener) Shorten(ctx context.Context, record storage.URLRecord) (string, error) {
+	if record.ShortURL != "" {
+		state, err := s.checkID(ctx, record.ShortURL, record.OriginalURL)
+		if err != nil {
+			return "", err
+		}
+		switch state {
+		case idTaken:
+			return "", ErrAliasTaken
+		case idSameURL:
+			return record.ShortURL, storage.ErrConflict
+		}
+		return record.ShortURL, s.store.AddURL(ctx, record)
+	}
+
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id, err := s.gen.Generate(record.OriginalURL, attempt)
+		if err != nil {
+			return "", err
+		}
+		state, err := s.checkID(ctx, id, record.OriginalURL)
+		if err != nil {
+			return "", err
+		}
+		switch state {
+		case idTaken:
+			continue
+		case idSameURL:
+			// хранилища по-разному отвечают на повторную запись, поэтому
+			// её не выполняем
+			return id, storage.ErrConflict
+		}
+
+		record.ShortURL = id
+		err = s.store.AddURL(ctx, record)
+		if !errors.Is(err, storage.ErrConflict) {
+			return id, err
+		}
+		// идентификатор могли занять между проверкой и записью
+		if state, err = s.checkID(ctx, id, record.OriginalURL); err != nil {
+			return "", err
+		}
+		if state == idSameURL {
+			return id, storage.ErrConflict
+		}
+	}
+	return "", ErrIDExhausted
+}
+
+// ShortenBatch выделяет идентификаторы всем ссылкам пачки и сохраняет их
+// одним вызовом AddURLBatch. Ссылки, уже сохранённые под тем же
+// идентификатором, повторно не записываются. Возвращает идентификаторы
+// в порядке записей. Ошибки отдельных элементов оборачиваются в *BatchError.
+func (s *Shortener) ShortenBatch(ctx context.Context, records []storage.URLRecord) ([]string, error) {
+	ids := make([]string, len(records))
+	reserved := make(map[string]string, len(records))
+	toAdd := make([]storage.URLRecord, 0, len(records))
+
+	for i, record := range records {
+		id, stored, err := s.allocateBatchID(ctx, record, reserved)
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		ids[i] = id
+		if _, ok := reserved[id]; ok {
+			continue
+		}
+		reserved[id] = record.OriginalURL
+		if stored {
+			continue
+		}
+		record.ShortURL = id
+		toAdd = append(toAdd, record)
+	}
+
+	if len(toAdd) > 0 {
+		if err := s.store.AddURLBatch(ctx, toAdd); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// allocateBatchID выбирает идентификатор для элемента пачки с учётом уже
+// выделенных в ней. stored сообщает, что ссылка уже сохранена в хранилище
+// под этим идентификатором.
+func (s *Shortener) allocateBatchID(ctx context.Context, record storage.URLRecord, reserved map[string]string) (id string, stored bool, err error) {
+	check := func(id string) (idState, bool, error) {
+		if original, ok := reserved[id]; ok {
+			if original == record.OriginalURL {
+				return idSameURL, false, nil
+			}
+			return idTaken, false, nil
+		}
+		state, err := s.checkID(ctx, id, record.OriginalURL)
+		return state, state == idSameURL, err
+	}
+
+	if record.ShortURL != "" {
+		state, stored, err := check(record.ShortURL)
+		if err != nil {
+			return "", false, err
+		}
+		if state == idTaken {
+			return "", false, ErrAliasTaken
+		}
+		return record.ShortURL, stored, nil
+	}
+
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id, err := s.gen.Generate(record.OriginalURL, attempt)
+		if err != nil {
+			return "", false, err
+		}
+		state, stored, err := check(id)
+		if err != nil {
+			return "", false, err
+		}
+		if state != idTaken {
+			return id, stored, nil
+		}
+	}
+	return "", false, ErrIDExhausted
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sequenceGenerator []string
+
+func (g sequenceGenerator) Generate(_ string, attempt int) (string, error) {
+	return g[attempt%len(g)], nil
+}
+
+func TestShortenerRetriesOnCollision(t *testing.T) {
+	ctx := context.Background()
+	store := storage.InitMemoryStore()
+	require.NoError(t, store.AddURL(ctx, storage.URLRecord{ShortURL: "aaaa", OriginalURL: "https://example.com/first"}))
+
+	shortener := NewShortener(store, sequenceGenerator{"aaaa", "bbbb"})
+
+	id, err := shortener.Shorten(ctx, storage.URLRecord{OriginalURL: "https://example.com/second"})
+	require.NoError(t, err)
+	assert.Equal(t, "bbbb", id, "colliding ID must not be reused for another URL")
+
+	original, err := store.GetURL(ctx, "aaaa")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/first", original)
+
+	id, err = shortener.Shorten(ctx, storage.URLRecord{OriginalURL: "https://example.com/first"})
+	assert.Equal(t, "aaaa", id)
+	assert.ErrorIs(t, err, storage.ErrConflict)
+
+	ids, err := shortener.ShortenBatch(ctx, []storage.URLRecord{
+		{OriginalURL: "https://example.com/third"},
+		{OriginalURL: "https://example.com/fourth"},
+	})
+	assert.ErrorIs(t, err, ErrIDExhausted, "both candidates are taken")
+	assert.Nil(t, ids)
+
+	_, err = NewShortener(store, sequenceGenerator{"aaaa"}).Shorten(ctx, storage.URLRecord{OriginalURL: "https://example.com/fifth"})
+	assert.ErrorIs(t, err, ErrIDExhausted)
+}
+
+func TestShortenerBatchCollisions(t *testing.T) {
+	bolt, err := storage.InitBoltStore(filepath.Join(t.TempDir(), "urls.db"))
+	require.NoError(t, err)
+	defer bolt.Close()
+
+	stores := map[string]storage.URLStore{
+		"memory": storage.InitMemoryStore(),
+		"bolt":   bolt,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			shortener := NewShortener(store, sequenceGenerator{"aaaa", "bbbb", "cccc"})
+			batch := []storage.URLRecord{
+				{OriginalURL: "https://example.com/first"},
+				{OriginalURL: "https://example.com/second"},
+				{OriginalURL: "https://example.com/first", ShortURL: "alias"},
+			}
+
+			ids, err := shortener.ShortenBatch(ctx, batch)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"aaaa", "bbbb", "alias"}, ids)
+
+			// повторная пачка не пишет уже сохранённые ссылки заново
+			ids, err = shortener.ShortenBatch(ctx, append(batch, storage.URLRecord{OriginalURL: "https://example.com/third"}))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"aaaa", "bbbb", "alias", "cccc"}, ids)
+			n, err := store.CountURLs(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 4, n)
+		})
+	}
+}
+
+func TestShortenerRepeat(t *testing.T) {
+	dir := t.TempDir()
+	file, err := storage.InitFileStore(filepath.Join(dir, "urls.json"))
+	require.NoError(t, err)
+	defer file.Close()
+	bolt, err := storage.InitBoltStore(filepath.Join(dir, "urls.db"))
+	require.NoError(t, err)
+	defer bolt.Close()
+
+	// хранилища по-разному отвечают на повторную запись, а Shorten — одинаково
+	stores := map[string]storage.URLStore{
+		"memory": storage.InitMemoryStore(),
+		"file":   file,
+		"bolt":   bolt,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			shortener := NewShortener(store, sequenceGenerator{"aaaa"})
+			testCases := []struct {
+				name   string
+				record storage.URLRecord
+				id     string
+			}{
+				{name: "Generated ID", record: storage.URLRecord{OriginalURL: "https://example.com/first"}, id: "aaaa"},
+				{name: "Alias", record: storage.URLRecord{OriginalURL: "https://example.com/second", ShortURL: "alias"}, id: "alias"},
+			}
+			for _, tc := range testCases {
+				id, err := shortener.Shorten(ctx, tc.record)
+				require.NoError(t, err, tc.name)
+				assert.Equal(t, tc.id, id, tc.name)
+
+				id, err = shortener.Shorten(ctx, tc.record)
+				assert.ErrorIs(t, err, storage.ErrConflict, tc.name)
+				assert.Equal(t, tc.id, id, tc.name)
+			}
+			n, err := store.CountURLs(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+		})
+	}
+}
+
+func TestIDGenerators(t *testing.T) {
+	hash, err := NewIDGenerator(IDGeneratorHash, 0)
+	require.NoError(t, err)
+	first, _ := hash.Generate("https://example.com", 0)
+	retry, _ := hash.Generate("https://example.com", 1)
+	assert.Equal(t, GenerateShortURLID("https://example.com"), first)
+	assert.NotEqual(t, first, retry)
+
+	random, err := NewIDGenerator(IDGeneratorRandom, 10)
+	require.NoError(t, err)
+	id, err := random.Generate("", 0)
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-zA-Z]{10}$`), id)
+
+	counter := NewCounterIDGenerator(60)
+	a, _ := counter.Generate("", 0)
+	b, _ := counter.Generate("", 0)
+	assert.Equal(t, "Z", a)
+	assert.Equal(t, "10", b)
+
+	_, err = NewIDGenerator("unknown", 0)
+	assert.Error(t, err)
+}
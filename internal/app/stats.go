@@ -0,0 +1,86 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ma-shulgin/go-link-shortener/internal/analytics"
+	"github.com/ma-shulgin/go-link-shortener/internal/auth"
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+const topReferrers = 10
+
+type statsResponse struct {
+	ShortURL string `json:"short_url"`
+	analytics.Stats
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// newClick описывает переход. Адрес посетителя определяется так же, как
+// для ограничения частоты запросов, иначе за прокси все посетители
+// считались бы одним.
+func newClick(r *http.Request, urlID, ipSalt string, limiter *RateLimiter) analytics.Click {
+	return analytics.Click{
+		ShortURL:  urlID,
+		Timestamp: time.Now().UTC(),
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		IPHash:    analytics.HashIP(limiter.remoteIP(r), ipSalt),
+	}
+}
+
+func handleStats(urlStorage storage.URLStore, clicks analytics.Store, baseURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if !auth.IsAuthenticated(ctx) {
+			writeError(w, errUnauthenticated)
+			return
+		}
+
+		urlID := chi.URLParam(r, "id")
+		// статистика удалённых и просроченных ссылок остаётся доступной владельцу
+		record, err := urlStorage.GetRecord(ctx, urlID)
+		if err != nil {
+			if !errors.Is(err, storage.ErrNotFound) {
+				logger.Log.Error("Failed to check URL owner", zap.Error(err))
+			}
+			writeError(w, err)
+			return
+		}
+		if record.UserID != auth.UserIDFromContext(ctx) {
+			writeError(w, fmt.Errorf("%w: short URL belongs to another user", errForbidden))
+			return
+		}
+
+		stats, err := clicks.Stats(ctx, urlID, topReferrers)
+		if err != nil {
+			logger.Log.Error("Failed to get stats", zap.Error(err))
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(statsResponse{ShortURL: baseURL + "/" + urlID, Stats: stats}); err != nil {
+			logger.Log.Debug("error encoding response", zap.Error(err))
+			return
+		}
+	}
+}
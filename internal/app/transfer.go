@@ -0,0 +1,542 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/auth"
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// AdminTokenHeader передаёт токен администратора, открывающий
+	// выгрузку всех ссылок и загрузку с сохранением владельцев.
+	AdminTokenHeader = "X-Admin-Token"
+
+	contentTypeNDJSON = "application/x-ndjson"
+	contentTypeCSV    = "text/csv"
+
+	exportPageSize   = 500
+	importChunkSize  = 100
+	importMaxLineLen = 1 << 20
+)
+
+const (
+	importCreated  = "created"
+	importConflict = "conflict"
+	importInvalid  = "invalid"
+)
+
+var csvColumns = []string{"id", "original_url", "user_id", "expires_at", "is_deleted"}
+
+var errInvalidRecord = errors.New("invalid record")
+
+// transferRecord — строка выгрузки и загрузки ссылок.
+type transferRecord struct {
+	ID          string     `json:"id"`
+	OriginalURL string     `json:"original_url"`
+	UserID      string     `json:"user_id,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Deleted     bool       `json:"is_deleted,omitempty"`
+}
+
+func isAdmin(r *http.Request, adminToken string) bool {
+	token := r.Header.Get(AdminTokenHeader)
+	return adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+// recordWriter пишет выгрузку в выбранном формате.
+type recordWriter interface {
+	Write(record transferRecord) error
+	Flush() error
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (w ndjsonWriter) Write(record transferRecord) error {
+	return w.enc.Encode(record)
+}
+
+func (w ndjsonWriter) Flush() error {
+	return nil
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (w csvWriter) Write(record transferRecord) error {
+	var expiresAt, deleted string
+	if record.ExpiresAt != nil {
+		expiresAt = record.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if record.Deleted {
+		deleted = "true"
+	}
+	return w.w.Write([]string{record.ID, record.OriginalURL, record.UserID, expiresAt, deleted})
+}
+
+func (w csvWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// handleExport выгружает ссылки пользователя, а с ?scope=all и токеном
+// администратора — все ссылки хранилища, включая удалённые и просроченные.
+// Формат выбирается по заголовку Accept: text/csv или JSON Lines.
+func handleExport(urlStorage storage.URLStore, adminToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		all := r.URL.Query().Get("scope") == "all"
+		if all && !isAdmin(r, adminToken) {
+			writeError(w, fmt.Errorf("%w: exporting all URLs requires the admin token", errForbidden))
+			return
+		}
+		if !all && !auth.IsAuthenticated(ctx) {
+			writeError(w, errUnauthenticated)
+			return
+		}
+
+		var out recordWriter
+		if strings.Contains(r.Header.Get("Accept"), contentTypeCSV) {
+			w.Header().Set("Content-Type", contentTypeCSV)
+			cw := csv.NewWriter(w)
+			if err := cw.Write(csvColumns); err != nil {
+				logger.Log.Debug("error writing export", zap.Error(err))
+				return
+			}
+			out = csvWriter{w: cw}
+		} else {
+			w.Header().Set("Content-Type", contentTypeNDJSON)
+			out = ndjsonWriter{enc: json.NewEncoder(w)}
+		}
+
+		var err error
+		if all {
+			err = exportAll(w, urlStorage, r, out)
+		} else {
+			err = exportUser(urlStorage, r, out)
+		}
+		if err == nil {
+			err = out.Flush()
+		}
+		if err != nil {
+			// заголовки уже отправлены, клиент увидит оборванную выгрузку
+			logger.Log.Error("Failed to export URLs", zap.Error(err))
+		}
+	}
+}
+
+func exportUser(urlStorage storage.URLStore, r *http.Request, out recordWriter) error {
+	ctx := r.Context()
+	records, err := urlStorage.GetUserURLs(ctx, auth.UserIDFromContext(ctx))
+	if err != nil {
+		return err
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ShortURL < records[j].ShortURL })
+	for _, record := range records {
+		if err := out.Write(transferRecord{
+			ID:          record.ShortURL,
+			OriginalURL: record.OriginalURL,
+			ExpiresAt:   record.ExpiresAt,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportAll(w http.ResponseWriter, urlStorage storage.URLStore, r *http.Request, out recordWriter) error {
+	ctx := r.Context()
+	after := ""
+	for {
+		records, err := urlStorage.ScanURLs(ctx, after, exportPageSize)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		for _, record := range records {
+			if err := out.Write(transferRecord{
+				ID:          record.ShortURL,
+				OriginalURL: record.OriginalURL,
+				UserID:      record.UserID,
+				ExpiresAt:   record.ExpiresAt,
+				Deleted:     record.DeletedFlag,
+			}); err != nil {
+				return err
+			}
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+		http.NewResponseController(w).Flush()
+		after = records[len(records)-1].ShortURL
+	}
+}
+
+// recordReader читает загрузку построчно. Ошибка, обёрнутая в
+// errInvalidRecord, относится к одной строке, и чтение можно продолжать.
+type recordReader interface {
+	Read() (record transferRecord, line int, err error)
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), importMaxLineLen)
+	return &ndjsonReader{scanner: scanner}
+}
+
+func (r *ndjsonReader) Read() (transferRecord, int, error) {
+	var record transferRecord
+	for r.scanner.Scan() {
+		r.line++
+		data := r.scanner.Bytes()
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return record, r.line, fmt.Errorf("%w: %v", errInvalidRecord, err)
+		}
+		return record, r.line, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return record, r.line + 1, err
+	}
+	return record, r.line, io.EOF
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+	line    int // строка последней прочитанной записи
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["original_url"]; !ok {
+		return nil, errors.New("CSV header must contain an original_url column")
+	}
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+func (r *csvReader) field(row []string, name string) string {
+	if i, ok := r.columns[name]; ok && i < len(row) {
+		return strings.TrimSpace(row[i])
+	}
+	return ""
+}
+
+func (r *csvReader) Read() (transferRecord, int, error) {
+	var record transferRecord
+	row, err := r.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return record, parseErr.StartLine, fmt.Errorf("%w: %v", errInvalidRecord, parseErr.Err)
+	}
+	if err != nil {
+		// после ошибки текущей записи нет, и FieldPos вызывать нельзя
+		return record, r.line + 1, err
+	}
+	line, _ := r.r.FieldPos(0)
+	r.line = line
+
+	record.ID = r.field(row, "id")
+	record.OriginalURL = r.field(row, "original_url")
+	record.UserID = r.field(row, "user_id")
+	if s := r.field(row, "expires_at"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return record, line, fmt.Errorf("%w: expires_at: %v", errInvalidRecord, err)
+		}
+		record.ExpiresAt = &t
+	}
+	if s := r.field(row, "is_deleted"); s != "" {
+		deleted, err := strconv.ParseBool(s)
+		if err != nil {
+			return record, line, fmt.Errorf("%w: is_deleted: %v", errInvalidRecord, err)
+		}
+		record.Deleted = deleted
+	}
+	return record, line, nil
+}
+
+type importLine struct {
+	Line     int    `json:"line"`
+	Status   string `json:"status"`
+	ShortURL string `json:"short_url,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type importReport struct {
+	Created   int          `json:"created"`
+	Conflicts int          `json:"conflicts"`
+	Invalid   int          `json:"invalid"`
+	Lines     []importLine `json:"lines"`
+}
+
+func (rep *importReport) add(line int, status, shortURL, message string) {
+	switch status {
+	case importCreated:
+		rep.Created++
+	case importConflict:
+		rep.Conflicts++
+	case importInvalid:
+		rep.Invalid++
+	}
+	rep.Lines = append(rep.Lines, importLine{Line: line, Status: status, ShortURL: shortURL, Error: message})
+}
+
+// importer сохраняет загружаемые ссылки пачками через AddURLBatch.
+// Строкам без id идентификатор выделяется заранее, как в ShortenBatch.
+type importer struct {
+	store     storage.URLStore
+	shortener *Shortener
+	aliases   AliasPolicy
+	urls      urlChecker
+	baseURL   string
+	userID    string
+	admin     bool
+	now       time.Time
+
+	report importReport
+	lines  []int
+	chunk  []storage.URLRecord
+	// generated отмечает записи пачки, идентификатор которых выделен сервисом
+	generated []bool
+	// reserved — идентификаторы пачки и их ссылки
+	reserved map[string]string
+}
+
+func (im *importer) add(r *http.Request, record transferRecord, line int) error {
+	originalURL, err := im.urls.check(record.OriginalURL)
+	if err != nil {
+		im.report.add(line, importInvalid, "", err.Error())
+		return nil
+	}
+	// просроченные ссылки из резервной копии восстанавливаются как есть
+	if record.ExpiresAt != nil && !im.admin {
+		if _, err := expiryTime(record.ExpiresAt, 0, im.now); err != nil {
+			im.report.add(line, importInvalid, "", err.Error())
+			return nil
+		}
+	}
+
+	urlRecord := storage.URLRecord{
+		ShortURL:    record.ID,
+		OriginalURL: originalURL,
+		UserID:      im.userID,
+		ExpiresAt:   record.ExpiresAt,
+	}
+	// администратор восстанавливает резервную копию как есть
+	if im.admin {
+		urlRecord.UserID = record.UserID
+		urlRecord.DeletedFlag = record.Deleted
+	}
+
+	if record.ID != "" {
+		if err := im.aliases.Validate(record.ID); err != nil {
+			im.report.add(line, importInvalid, "", err.Error())
+			return nil
+		}
+	}
+
+	if im.reserved == nil {
+		im.reserved = make(map[string]string, importChunkSize)
+	}
+	id, stored, err := im.shortener.allocateBatchID(r.Context(), urlRecord, im.reserved)
+	if errors.Is(err, ErrAliasTaken) {
+		im.report.add(line, importConflict, im.baseURL+"/"+record.ID, "id is already taken")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := im.reserved[id]; ok || stored {
+		im.report.add(line, importConflict, im.baseURL+"/"+id, "URL is already shortened")
+		return nil
+	}
+
+	im.reserved[id] = originalURL
+	urlRecord.ShortURL = id
+	im.chunk = append(im.chunk, urlRecord)
+	im.lines = append(im.lines, line)
+	im.generated = append(im.generated, record.ID == "")
+	if len(im.chunk) >= importChunkSize {
+		return im.flush(r)
+	}
+	return nil
+}
+
+// flush сохраняет накопленную пачку. Если хранилище отвергло её из-за
+// конфликта (идентификатор заняли после проверки), записи сохраняются по
+// одной, чтобы найти конфликтующие.
+func (im *importer) flush(r *http.Request) error {
+	if len(im.chunk) == 0 {
+		return nil
+	}
+	ctx := r.Context()
+	err := im.store.AddURLBatch(ctx, im.chunk)
+	switch {
+	case err == nil:
+		for i, record := range im.chunk {
+			im.report.add(im.lines[i], importCreated, im.baseURL+"/"+record.ShortURL, "")
+		}
+	case errors.Is(err, storage.ErrConflict):
+		for i, record := range im.chunk {
+			if err := im.addOne(ctx, record, im.lines[i], im.generated[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		return err
+	}
+	im.chunk = im.chunk[:0]
+	im.lines = im.lines[:0]
+	im.generated = im.generated[:0]
+	clear(im.reserved)
+	return nil
+}
+
+// addOne сохраняет одну запись пачки. Выделенный сервисом идентификатор
+// при конфликте выделяется заново, а запись, уже сохранённая с той же
+// ссылкой, отмечается как конфликт, а не как созданная.
+func (im *importer) addOne(ctx context.Context, record storage.URLRecord, line int, generated bool) error {
+	if generated {
+		record.ShortURL = ""
+		id, err := im.shortener.Shorten(ctx, record)
+		switch {
+		case errors.Is(err, storage.ErrConflict):
+			im.report.add(line, importConflict, im.baseURL+"/"+id, "URL is already shortened")
+		case err != nil:
+			return err
+		default:
+			im.report.add(line, importCreated, im.baseURL+"/"+id, "")
+		}
+		return nil
+	}
+
+	shortURL := im.baseURL + "/" + record.ShortURL
+	state, err := im.shortener.checkID(ctx, record.ShortURL, record.OriginalURL)
+	if err != nil {
+		return err
+	}
+	switch state {
+	case idSameURL:
+		im.report.add(line, importConflict, shortURL, "URL is already shortened")
+		return nil
+	case idTaken:
+		im.report.add(line, importConflict, shortURL, "id is already taken")
+		return nil
+	}
+	err = im.store.AddURL(ctx, record)
+	switch {
+	case errors.Is(err, storage.ErrConflict):
+		im.report.add(line, importConflict, shortURL, "id is already taken")
+	case err != nil:
+		return err
+	default:
+		im.report.add(line, importCreated, shortURL, "")
+	}
+	return nil
+}
+
+// handleImport загружает ссылки в формате JSON Lines или CSV (по Content-Type)
+// и возвращает отчёт по каждой строке. Сжатое тело распаковывает gzipMiddleware.
+func handleImport(urlStorage storage.URLStore, shortener *Shortener, baseURL string, aliases AliasPolicy, urls urlChecker, adminToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		var in recordReader
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == contentTypeCSV {
+			cr, err := newCSVReader(r.Body)
+			if err != nil {
+				writeError(w, bodyError(err))
+				return
+			}
+			in = cr
+		} else {
+			in = newNDJSONReader(r.Body)
+		}
+
+		im := &importer{
+			store:     urlStorage,
+			shortener: shortener,
+			aliases:   aliases,
+			urls:      urls,
+			baseURL:   baseURL,
+			userID:    auth.UserIDFromContext(r.Context()),
+			admin:     isAdmin(r, adminToken),
+			now:       time.Now(),
+			report:    importReport{Lines: []importLine{}},
+		}
+		for {
+			record, line, err := in.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if errors.Is(err, errInvalidRecord) {
+				im.report.add(line, importInvalid, "", err.Error())
+				continue
+			}
+			if err != nil {
+				// ссылки из уже прочитанных строк к этому моменту могут быть сохранены
+				writeError(w, bodyError(fmt.Errorf("line %d: %w", line, err)))
+				return
+			}
+			if err := im.add(r, record, line); err != nil {
+				logger.Log.Error("Failed to import URLs", zap.Error(err))
+				writeError(w, err)
+				return
+			}
+		}
+		if err := im.flush(r); err != nil {
+			logger.Log.Error("Failed to import URLs", zap.Error(err))
+			writeError(w, err)
+			return
+		}
+
+		sort.SliceStable(im.report.Lines, func(i, j int) bool { return im.report.Lines[i].Line < im.report.Lines[j].Line })
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(im.report); err != nil {
+			logger.Log.Debug("error encoding response", zap.Error(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+const DefaultURLMaxLength = 2048
+
+var ErrInvalidURL = errors.New("invalid URL")
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// URLPolicy описывает, какие ссылки можно сокращать и как они приводятся
+// к каноническому виду, чтобы одинаковые ссылки получали один идентификатор.
+type URLPolicy struct {
+	AllowedSchemes   []string
+	StripFragment    bool
+	StripDefaultPort bool
+	MaxLength        int
+}
+
+func DefaultURLPolicy() URLPolicy {
+	return URLPolicy{
+		AllowedSchemes:   []string{"http", "https"},
+		StripFragment:    true,
+		StripDefaultPort: true,
+		MaxLength:        DefaultURLMaxLength,
+	}
+}
+
+// Normalize проверяет ссылку и возвращает её канонический вид: без пробелов
+// по краям, с хостом в нижнем регистре и в punycode, без порта по умолчанию
+// и фрагмента, если политика это предписывает.
+func (p URLPolicy) Normalize(raw string) (string, error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return "", fmt.Errorf("%w: URL is empty", ErrInvalidURL)
+	}
+	if p.MaxLength > 0 && len(s) > p.MaxLength {
+		return "", fmt.Errorf("%w: URL is longer than %d bytes", ErrInvalidURL, p.MaxLength)
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidURL, errors.Unwrap(err))
+	}
+	if u.Scheme == "" {
+		return "", fmt.Errorf("%w: URL must be absolute", ErrInvalidURL)
+	}
+	if !p.schemeAllowed(u.Scheme) {
+		return "", fmt.Errorf("%w: scheme %q is not allowed", ErrInvalidURL, u.Scheme)
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return "", fmt.Errorf("%w: URL must have a host", ErrInvalidURL)
+	}
+
+	host, port := u.Hostname(), u.Port()
+	if strings.Contains(host, ":") {
+		// IPv6-адрес: в нём нет букв, кроме шестнадцатеричных цифр
+		host = "[" + strings.ToLower(host) + "]"
+	} else {
+		host, err = idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", fmt.Errorf("%w: host: %v", ErrInvalidURL, err)
+		}
+		host = strings.ToLower(host)
+	}
+	if p.StripDefaultPort && port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	if p.StripFragment {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+	return u.String(), nil
+}
+
+func (p URLPolicy) schemeAllowed(scheme string) bool {
+	for _, allowed := range p.AllowedSchemes {
+		if strings.EqualFold(scheme, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// urlChecker нормализует ссылку и проверяет её домен перед сокращением.
+type urlChecker struct {
+	policy  URLPolicy
+	domains *DomainPolicy
+}
+
+func (c urlChecker) check(raw string) (string, error) {
+	normalized, err := c.policy.Normalize(raw)
+	if err != nil {
+		return "", err
+	}
+	if err := c.domains.Check(normalized); err != nil {
+		return "", err
+	}
+	return normalized, nil
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLPolicyNormalize(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   URLPolicy
+		raw      string
+		expected string
+		wantErr  bool
+	}{
+		{name: "Trims and lowercases host", policy: DefaultURLPolicy(), raw: "  https://Example.COM/Path?q=A \n", expected: "https://example.com/Path?q=A"},
+		{name: "Strips default port and fragment", policy: DefaultURLPolicy(), raw: "https://example.com:443/a#top", expected: "https://example.com/a"},
+		{name: "Keeps other ports", policy: DefaultURLPolicy(), raw: "http://example.com:8080/", expected: "http://example.com:8080/"},
+		{name: "IDN to punycode", policy: DefaultURLPolicy(), raw: "https://пример.рф/", expected: "https://xn--e1afmkfd.xn--p1ai/"},
+		{name: "IPv6 literal", policy: DefaultURLPolicy(), raw: "http://[::1]:80/", expected: "http://[::1]/"},
+		{name: "Keeps fragment and port when allowed", policy: URLPolicy{AllowedSchemes: []string{"https"}}, raw: "https://example.com:443/#top", expected: "https://example.com:443/#top"},
+		{name: "Scheme is case insensitive", policy: DefaultURLPolicy(), raw: "HTTPS://example.com", expected: "https://example.com"},
+		{name: "Empty", policy: DefaultURLPolicy(), raw: "  ", wantErr: true},
+		{name: "Relative", policy: DefaultURLPolicy(), raw: "example.com/a", wantErr: true},
+		{name: "Forbidden scheme", policy: DefaultURLPolicy(), raw: "javascript:alert(1)", wantErr: true},
+		{name: "Opaque URL", policy: URLPolicy{AllowedSchemes: []string{"mailto"}}, raw: "mailto:user@example.com", wantErr: true},
+		{name: "No host", policy: DefaultURLPolicy(), raw: "https:///path", wantErr: true},
+		{name: "Too long", policy: URLPolicy{AllowedSchemes: []string{"https"}, MaxLength: 20}, raw: "https://example.com/long", wantErr: true},
+		{name: "Unparsable", policy: DefaultURLPolicy(), raw: "https://exa mple.com/", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.policy.Normalize(tc.raw)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidURL)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestShortenNormalizesURL(t *testing.T) {
+	store := storage.InitMemoryStore()
+	ts := httptest.NewServer(RootRouter(store, "http://localhost:8080"))
+	defer ts.Close()
+
+	post := func(path, contentType, body string) (int, string) {
+		resp, err := http.Post(ts.URL+path, contentType, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+
+	code, first := post("/", "text/plain", "https://Example.com:443/x#f")
+	assert.Equal(t, http.StatusCreated, code)
+	_, second := post("/", "text/plain", "https://example.com/x")
+	assert.Equal(t, first, second)
+
+	id := strings.TrimPrefix(first, "http://localhost:8080/")
+	got, err := store.GetURL(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/x", got)
+
+	code, body := post("/", "text/plain", "javascript:alert(1)")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid URL: scheme \"javascript\" is not allowed\n", body)
+
+	// текстовый API отдаёт problem+json, если клиент просит JSON
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/", strings.NewReader("javascript:alert(1)"))
+	require.NoError(t, err)
+	req.Header.Set("Accept", contentTypeProblem)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, contentTypeProblem, resp.Header.Get("Content-Type"))
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "about:blank", "title": "Bad Request", "status": 400, "code": "invalid_url",
+		"detail": "invalid URL: scheme \"javascript\" is not allowed", "url": "javascript:alert(1)"}`, string(data))
+
+	code, _ = post("/api/shorten", "application/json", `{"url": "ftp://example.com/file"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body = post("/api/shorten/batch", "application/json",
+		`[{"correlation_id": "1", "original_url": "https://example.com/ok"}, {"correlation_id": "2", "original_url": "not a url"}]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, `"correlation_id":"2"`)
+}
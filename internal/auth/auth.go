@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	CookieName = "auth"
+	HeaderName = "Authorization"
+
+	bearerPrefix = "Bearer "
+	userIDLength = 16
+)
+
+var ErrInvalidToken = errors.New("invalid auth token")
+
+type contextKey struct{}
+
+// identity описывает пользователя, от имени которого выполняется запрос.
+type identity struct {
+	userID string
+	// authenticated равен true, если клиент предъявил валидный токен,
+	// и false, если идентификатор был выдан в рамках текущего запроса.
+	authenticated bool
+}
+
+// Signer выпускает и проверяет токены вида <userID>.<HMAC-SHA256(userID)>.
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(secret string) *Signer {
+	return &Signer{key: []byte(secret)}
+}
+
+// NewRandomSigner создаёт Signer со случайным ключом. Токены, выпущенные им,
+// перестают быть валидными после перезапуска сервиса.
+func NewRandomSigner() (*Signer, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &Signer{key: key}, nil
+}
+
+func (s *Signer) sign(userID string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(userID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Signer) Issue(userID string) string {
+	return userID + "." + s.sign(userID)
+}
+
+func (s *Signer) Verify(token string) (string, error) {
+	userID, signature, ok := strings.Cut(token, ".")
+	if !ok || userID == "" {
+		return "", ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(userID))) {
+		return "", ErrInvalidToken
+	}
+	return userID, nil
+}
+
+func NewUserID() (string, error) {
+	b := make([]byte, userIDLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// tokenFromRequest достаёт токен из заголовка Authorization или из cookie.
+func tokenFromRequest(r *http.Request) string {
+	if h := r.Header.Get(HeaderName); strings.HasPrefix(h, bearerPrefix) {
+		return strings.TrimPrefix(h, bearerPrefix)
+	}
+	if c, err := r.Cookie(CookieName); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// Middleware проверяет токен пользователя. Если токен отсутствует или подпись
+// не сходится, пользователю выдаётся новый идентификатор в cookie и в заголовке
+// Authorization ответа. Ошибки отдаются через writeError, чтобы ответ был в
+// том же формате, что и ошибки обработчиков.
+func Middleware(signer *Signer, writeError func(http.ResponseWriter, error)) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if userID, err := signer.Verify(tokenFromRequest(r)); err == nil {
+				ctx := context.WithValue(r.Context(), contextKey{}, identity{userID: userID, authenticated: true})
+				h.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			userID, err := NewUserID()
+			if err != nil {
+				logger.Log.Error("cannot generate user ID", zap.Error(err))
+				writeError(w, fmt.Errorf("generate user ID: %w", err))
+				return
+			}
+			token := signer.Issue(userID)
+			http.SetCookie(w, &http.Cookie{
+				Name:     CookieName,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+			})
+			w.Header().Set(HeaderName, bearerPrefix+token)
+
+			ctx := context.WithValue(r.Context(), contextKey{}, identity{userID: userID})
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// UserIDFromContext возвращает идентификатор пользователя, выданный или
+// проверенный Middleware.
+func UserIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(identity)
+	return id.userID
+}
+
+// IsAuthenticated сообщает, предъявил ли клиент валидный токен.
+func IsAuthenticated(ctx context.Context) bool {
+	id, _ := ctx.Value(contextKey{}).(identity)
+	return id.authenticated
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey — ключ метаданных gRPC с токеном пользователя.
+const MetadataKey = "authorization"
+
+func tokenFromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get(MetadataKey) {
+		if strings.HasPrefix(v, bearerPrefix) {
+			return strings.TrimPrefix(v, bearerPrefix)
+		}
+	}
+	return ""
+}
+
+// UnaryServerInterceptor — аналог Middleware для gRPC. Токен читается из
+// метаданных authorization, новый токен возвращается в заголовке ответа
+// с тем же ключом.
+func UnaryServerInterceptor(signer *Signer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if userID, err := signer.Verify(tokenFromMetadata(ctx)); err == nil {
+			return handler(context.WithValue(ctx, contextKey{}, identity{userID: userID, authenticated: true}), req)
+		}
+
+		userID, err := NewUserID()
+		if err != nil {
+			logger.Log.Error("cannot generate user ID", zap.Error(err))
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+		if err := grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, bearerPrefix+signer.Issue(userID))); err != nil {
+			logger.Log.Debug("cannot send auth token", zap.Error(err))
+		}
+		return handler(context.WithValue(ctx, contextKey{}, identity{userID: userID}), req)
+	}
+}
@@ -0,0 +1,31 @@
+package logger
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// UnaryServerInterceptor — аналог WithLogging для gRPC.
+func UnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+
+	resp, err := handler(ctx, req)
+
+	duration := time.Since(start)
+
+	var size int
+	if msg, ok := resp.(proto.Message); ok {
+		size = proto.Size(msg)
+	}
+	Log.Infoln(
+		"method", info.FullMethod,
+		"code", status.Code(err).String(),
+		"duration", duration,
+		"size", size,
+	)
+	return resp, err
+}
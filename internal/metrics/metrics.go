@@ -0,0 +1,293 @@
+// Package metrics реализует минимальный набор метрик Prometheus
+// (счётчики, гистограммы и вычисляемые gauge) и их отдачу в текстовом формате.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets — границы гистограмм длительности в секундах.
+var DefBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry хранит метрики и отдаёт их в формате Prometheus.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// Default — реестр, в котором регистрируются метрики сервиса.
+var Default = NewRegistry()
+
+// register добавляет метрику; метрика с тем же именем заменяется.
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors[c.name()] = c
+}
+
+func (r *Registry) Write(w io.Writer) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]collector, 0, len(names))
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.RUnlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		bw := bufio.NewWriter(w)
+		r.Write(bw)
+		bw.Flush()
+	})
+}
+
+// series — набор значений меток одной временной серии.
+type series struct {
+	labels []string
+	values []string
+}
+
+func (s series) String() string {
+	if len(s.labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(s.labels))
+	for i, label := range s.labels {
+		pairs[i] = label + `="` + escapeLabel(s.values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (s series) with(label, value string) series {
+	return series{
+		labels: append(append([]string{}, s.labels...), label),
+		values: append(append([]string{}, s.values...), value),
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type counterValue struct {
+	series series
+	value  float64
+}
+
+// CounterVec — монотонно растущий счётчик с метками.
+type CounterVec struct {
+	metricName string
+	help       string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		values:     make(map[string]*counterValue),
+	}
+	Default.register(c)
+	return c
+}
+
+func (c *CounterVec) name() string {
+	return c.metricName
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := seriesKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cv, ok := c.values[key]
+	if !ok {
+		cv = &counterValue{series: series{labels: c.labels, values: append([]string{}, labelValues...)}}
+		c.values[key] = cv
+	}
+	cv.value += v
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value возвращает текущее значение серии.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cv, ok := c.values[seriesKey(labelValues)]; ok {
+		return cv.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	writeHeader(w, c.metricName, c.help, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		cv := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, cv.series, formatFloat(cv.value))
+	}
+}
+
+type histogramValue struct {
+	series  series
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// HistogramVec — гистограмма с метками.
+type HistogramVec struct {
+	metricName string
+	help       string
+	labels     []string
+	bounds     []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+func NewHistogramVec(name, help string, bounds []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		bounds:     bounds,
+		values:     make(map[string]*histogramValue),
+	}
+	Default.register(h)
+	return h
+}
+
+func (h *HistogramVec) name() string {
+	return h.metricName
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := seriesKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{
+			series:  series{labels: h.labels, values: append([]string{}, labelValues...)},
+			buckets: make([]uint64, len(h.bounds)),
+		}
+		h.values[key] = hv
+	}
+	for i, bound := range h.bounds {
+		if v <= bound {
+			hv.buckets[i]++
+		}
+	}
+	hv.sum += v
+	hv.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	writeHeader(w, h.metricName, h.help, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+		for i, bound := range h.bounds {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, hv.series.with("le", formatFloat(bound)), hv.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, hv.series.with("le", "+Inf"), hv.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, hv.series, formatFloat(hv.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, hv.series, hv.count)
+	}
+}
+
+// GaugeFunc — метрика, значение которой вычисляется в момент сбора.
+type GaugeFunc struct {
+	metricName string
+	help       string
+	kind       string
+	fn         func() float64
+}
+
+// RegisterGaugeFunc регистрирует вычисляемую метрику в реестре Default.
+func RegisterGaugeFunc(name, help string, fn func() float64) {
+	Default.register(&GaugeFunc{metricName: name, help: help, kind: "gauge", fn: fn})
+}
+
+// RegisterCounterFunc регистрирует вычисляемый счётчик, например счётчик,
+// который ведёт сам компонент.
+func RegisterCounterFunc(name, help string, fn func() float64) {
+	Default.register(&GaugeFunc{metricName: name, help: help, kind: "counter", fn: fn})
+}
+
+func (g *GaugeFunc) name() string {
+	return g.metricName
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.metricName, g.help, g.kind)
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(g.fn()))
+}
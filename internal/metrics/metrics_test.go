@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExposition(t *testing.T) {
+	reg := NewRegistry()
+	counter := &CounterVec{metricName: "test_total", help: "Test counter.", labels: []string{"kind"}, values: map[string]*counterValue{}}
+	histogram := &HistogramVec{metricName: "test_seconds", help: "Test histogram.", bounds: []float64{0.1, 1}, values: map[string]*histogramValue{}}
+	reg.register(counter)
+	reg.register(histogram)
+	reg.register(&GaugeFunc{metricName: "test_gauge", help: "Test gauge.", kind: "gauge", fn: func() float64 { return 42 }})
+
+	counter.Inc(`a"b`)
+	counter.Add(2, `a"b`)
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, contentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, `# HELP test_gauge Test gauge.
+# TYPE test_gauge gauge
+test_gauge 42
+# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 1
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 5.55
+test_seconds_count 3
+# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{kind="a\"b"} 3
+`, rec.Body.String())
+}
+
+func TestWithMetricsUsesRoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(WithMetrics)
+	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	before := HTTPRequests.Value("/{id}", http.MethodGet, "418")
+	for _, id := range []string{"abc", "def"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/"+id, nil))
+	}
+	assert.Equal(t, before+2, HTTPRequests.Value("/{id}", http.MethodGet, "418"))
+
+	rec := httptest.NewRecorder()
+	Default.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.Contains(rec.Body.String(), `shortener_http_request_duration_seconds_count{route="/{id}",method="GET",status="418"} 2`))
+}
@@ -0,0 +1,32 @@
+package metrics
+
+// Метрики сервиса. Компоненты обновляют их напрямую.
+var (
+	HTTPRequests = NewCounterVec(
+		"shortener_http_requests_total",
+		"Number of handled HTTP requests.",
+		"route", "method", "status",
+	)
+	HTTPRequestDuration = NewHistogramVec(
+		"shortener_http_request_duration_seconds",
+		"HTTP request latency.",
+		DefBuckets,
+		"route", "method", "status",
+	)
+	StorageOperationDuration = NewHistogramVec(
+		"shortener_storage_operation_duration_seconds",
+		"URL storage operation latency.",
+		DefBuckets,
+		"backend", "operation",
+	)
+	StorageErrors = NewCounterVec(
+		"shortener_storage_errors_total",
+		"Number of failed URL storage operations.",
+		"backend", "operation", "error",
+	)
+	GzipBytes = NewCounterVec(
+		"shortener_gzip_bytes_total",
+		"Bytes passed through the gzip middleware before and after (de)compression.",
+		"direction", "encoding",
+	)
+)
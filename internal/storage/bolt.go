@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Бакеты BoltStore: сами записи по короткому идентификатору и два индекса,
+// ключи которых содержат всю нужную информацию, а значения пусты.
+var (
+	urlsBucket   = []byte("urls")
+	userBucket   = []byte("urls_by_user")   // userID \x00 shortURL
+	expiryBucket = []byte("urls_by_expiry") // unix nano (big endian) shortURL
+)
+
+// BoltStore хранит ссылки во встроенной транзакционной базе bbolt.
+// Данные читаются с диска по запросу, поэтому время запуска не зависит
+// от их объёма.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func InitBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{urlsBucket, userBucket, expiryBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	logger.Log.Infow("Bolt storage opened", "path", path)
+	return &BoltStore{db: db}, nil
+}
+
+func userKey(userID, shortURL string) []byte {
+	return append([]byte(userID+"\x00"), shortURL...)
+}
+
+func expiryKey(expiresAt time.Time, shortURL string) []byte {
+	key := make([]byte, 8, 8+len(shortURL))
+	binary.BigEndian.PutUint64(key, uint64(expiresAt.UnixNano()))
+	return append(key, shortURL...)
+}
+
+// putRecord добавляет новую запись; как и в PostgresStore, любой занятый
+// идентификатор считается конфликтом.
+func putRecord(tx *bolt.Tx, record URLRecord) error {
+	urls := tx.Bucket(urlsBucket)
+	if urls.Get([]byte(record.ShortURL)) != nil {
+		return ErrConflict
+	}
+
+	id, err := urls.NextSequence()
+	if err != nil {
+		return err
+	}
+	record.UUID = int(id)
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := urls.Put([]byte(record.ShortURL), data); err != nil {
+		return err
+	}
+	if record.UserID != "" {
+		if err := tx.Bucket(userBucket).Put(userKey(record.UserID, record.ShortURL), nil); err != nil {
+			return err
+		}
+	}
+	if record.ExpiresAt != nil {
+		if err := tx.Bucket(expiryBucket).Put(expiryKey(*record.ExpiresAt, record.ShortURL), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getRecord(tx *bolt.Tx, shortURL string) (URLRecord, bool, error) {
+	var record URLRecord
+	data := tx.Bucket(urlsBucket).Get([]byte(shortURL))
+	if data == nil {
+		return record, false, nil
+	}
+	err := json.Unmarshal(data, &record)
+	return record, true, err
+}
+
+func (s *BoltStore) AddURL(ctx context.Context, record URLRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putRecord(tx, record)
+	})
+}
+
+// AddURLBatch добавляет все записи в одной транзакции: при конфликте
+// хотя бы одной из них не сохраняется ни одна.
+func (s *BoltStore) AddURLBatch(ctx context.Context, urls []URLRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, url := range urls {
+			if err := putRecord(tx, url); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) GetURL(ctx context.Context, shortURL string) (string, error) {
+	var record URLRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var found bool
+		var err error
+		record, found, err = getRecord(tx, shortURL)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrNotFound
+		}
+		return record.availability(time.Now())
+	})
+	if err != nil {
+		return "", err
+	}
+	return record.OriginalURL, nil
+}
+
+func (s *BoltStore) GetRecord(ctx context.Context, shortURL string) (URLRecord, error) {
+	var record URLRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var found bool
+		var err error
+		record, found, err = getRecord(tx, shortURL)
+		if err == nil && !found {
+			return ErrNotFound
+		}
+		return err
+	})
+	return record, err
+}
+
+func (s *BoltStore) GetUserURLs(ctx context.Context, userID string) ([]URLRecord, error) {
+	var records []URLRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		prefix := userKey(userID, "")
+		c := tx.Bucket(userBucket).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			record, found, err := getRecord(tx, string(k[len(prefix):]))
+			if err != nil {
+				return err
+			}
+			if found && record.availability(now) == nil {
+				records = append(records, record)
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+func (s *BoltStore) DeleteURLs(ctx context.Context, tasks []DeleteTask) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		urls := tx.Bucket(urlsBucket)
+		for _, task := range tasks {
+			record, found, err := getRecord(tx, task.ShortURL)
+			if err != nil {
+				return err
+			}
+			if !found || record.UserID != task.UserID || record.DeletedFlag {
+				continue
+			}
+			record.DeletedFlag = true
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			if err := urls.Put([]byte(record.ShortURL), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PurgeExpired проходит индекс сроков жизни по возрастанию и
+// останавливается на первой ещё не истёкшей записи.
+func (s *BoltStore) PurgeExpired(ctx context.Context) (int, error) {
+	purged := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		urls := tx.Bucket(urlsBucket)
+		users := tx.Bucket(userBucket)
+		now := uint64(time.Now().UnixNano())
+		c := tx.Bucket(expiryBucket).Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k[:8]) <= now; k, _ = c.First() {
+			shortURL := string(k[8:])
+			record, found, err := getRecord(tx, shortURL)
+			if err != nil {
+				return err
+			}
+			if found {
+				if err := urls.Delete([]byte(shortURL)); err != nil {
+					return err
+				}
+				if record.UserID != "" {
+					if err := users.Delete(userKey(record.UserID, shortURL)); err != nil {
+						return err
+					}
+				}
+				purged++
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+func (s *BoltStore) CountURLs(ctx context.Context) (int, error) {
+	var n int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(urlsBucket).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+func (s *BoltStore) ScanURLs(ctx context.Context, after string, limit int) ([]URLRecord, error) {
+	var records []URLRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(urlsBucket).Cursor()
+		k, v := c.Seek([]byte(after))
+		if k != nil && string(k) == after {
+			k, v = c.Next()
+		}
+		for ; k != nil && len(records) < limit; k, v = c.Next() {
+			var record URLRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
+
+func (s *BoltStore) Ping(ctx context.Context) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return nil
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
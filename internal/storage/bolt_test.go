@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func initTestBoltStore(t *testing.T) (*BoltStore, string) {
+	path := filepath.Join(t.TempDir(), "urls.db")
+	store, err := InitBoltStore(path)
+	require.NoError(t, err)
+	return store, path
+}
+
+func TestBoltStore(t *testing.T) {
+	ctx := context.Background()
+	store, path := initTestBoltStore(t)
+
+	require.NoError(t, store.AddURL(ctx, URLRecord{ShortURL: "abc", OriginalURL: "https://example.com", UserID: "user"}))
+	assert.ErrorIs(t, store.AddURL(ctx, URLRecord{ShortURL: "abc", OriginalURL: "https://example.com"}), ErrConflict)
+	assert.ErrorIs(t, store.AddURL(ctx, URLRecord{ShortURL: "abc", OriginalURL: "https://example.org"}), ErrConflict)
+
+	got, err := store.GetURL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", got)
+	_, err = store.GetURL(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	err = store.AddURLBatch(ctx, []URLRecord{
+		{ShortURL: "new", OriginalURL: "https://example.com/new"},
+		{ShortURL: "abc", OriginalURL: "https://example.com/other"},
+	})
+	assert.ErrorIs(t, err, ErrConflict)
+	_, err = store.GetURL(ctx, "new")
+	assert.ErrorIs(t, err, ErrNotFound, "failed batch must not be partially applied")
+
+	require.NoError(t, store.AddURLBatch(ctx, []URLRecord{
+		{ShortURL: "b1", OriginalURL: "https://example.com/1", UserID: "user"},
+		{ShortURL: "b2", OriginalURL: "https://example.com/2", UserID: "other"},
+	}))
+	records, err := store.GetUserURLs(ctx, "user")
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	require.NoError(t, store.DeleteURLs(ctx, []DeleteTask{
+		{UserID: "user", ShortURL: "abc"},
+		{UserID: "user", ShortURL: "b2"},
+	}))
+	_, err = store.GetURL(ctx, "abc")
+	assert.ErrorIs(t, err, ErrDeleted)
+	record, err := store.GetRecord(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "user", record.UserID)
+	assert.True(t, record.DeletedFlag)
+	_, err = store.GetRecord(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.GetURL(ctx, "b2")
+	assert.NoError(t, err, "other users' links must not be deleted")
+
+	require.NoError(t, store.Close())
+	reopened, err := InitBoltStore(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	n, err := reopened.CountURLs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	require.NoError(t, reopened.AddURL(ctx, URLRecord{ShortURL: "after", OriginalURL: "https://example.com/after", UserID: "user"}))
+	records, err = reopened.GetUserURLs(ctx, "user")
+	require.NoError(t, err)
+	ids := make(map[int]bool)
+	for _, record := range records {
+		assert.False(t, ids[record.UUID], "duplicate UUID %d", record.UUID)
+		ids[record.UUID] = true
+	}
+}
+
+func TestBoltStorePurgeExpired(t *testing.T) {
+	ctx := context.Background()
+	store, _ := initTestBoltStore(t)
+	defer store.Close()
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, store.AddURLBatch(ctx, []URLRecord{
+		{ShortURL: "expired", OriginalURL: "https://example.com/1", UserID: "user", ExpiresAt: &past},
+		{ShortURL: "alive", OriginalURL: "https://example.com/2", UserID: "user", ExpiresAt: &future},
+		{ShortURL: "forever", OriginalURL: "https://example.com/3", UserID: "user"},
+	}))
+
+	_, err := store.GetURL(ctx, "expired")
+	assert.ErrorIs(t, err, ErrExpired)
+
+	purged, err := store.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	_, err = store.GetURL(ctx, "expired")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	records, err := store.GetUserURLs(ctx, "user")
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+	n, err := store.CountURLs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestBoltStoreConcurrentAccess(t *testing.T) {
+	store, _ := initTestBoltStore(t)
+	defer store.Close()
+	// без fsync на каждую транзакцию, иначе тест идёт секунды
+	store.db.NoSync = true
+	stressStore(t, store)
+}
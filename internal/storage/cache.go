@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type CacheConfig struct {
+	// Size — максимальное число идентификаторов в кэше.
+	Size int
+	// TTL ограничивает время жизни найденных записей; 0 — без ограничения.
+	// Это же время задаёт, насколько кэш может отставать от изменений,
+	// сделанных в обход него другими экземплярами сервиса. Срок действия
+	// ссылки соблюдается независимо от TTL.
+	TTL time.Duration
+	// NegativeTTL — время хранения отсутствия идентификатора; 0 отключает
+	// кэширование неизвестных идентификаторов.
+	NegativeTTL time.Duration
+}
+
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+type cacheEntry struct {
+	shortURL    string
+	originalURL string
+	err         error
+	expiresAt   time.Time // нулевое значение — бессрочно
+}
+
+// CachedStore кэширует результаты GetURL в LRU ограниченного размера и
+// сбрасывает затронутые идентификаторы при записи и удалении через себя.
+// Промахи читаются из хранилища через GetRecord, чтобы запись не
+// пережила в кэше срок действия ссылки. Остальные методы передаются
+// хранилищу без изменений.
+type CachedStore struct {
+	store URLStore
+	cfg   CacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // от недавно использованных к давно использованным
+	// gen растёт при каждой инвалидации; результат чтения, начатого до неё,
+	// в кэш не попадает, иначе он мог бы вернуть уже перезаписанное значение
+	gen uint64
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+func NewCachedStore(store URLStore, cfg CacheConfig) *CachedStore {
+	return &CachedStore{
+		store:   store,
+		cfg:     cfg,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (s *CachedStore) Stats() CacheStats {
+	return CacheStats{
+		Hits:      s.hits.Load(),
+		Misses:    s.misses.Load(),
+		Evictions: s.evictions.Load(),
+	}
+}
+
+func (s *CachedStore) GetURL(ctx context.Context, shortURL string) (string, error) {
+	entry, gen, ok := s.lookup(shortURL, time.Now())
+	if ok {
+		s.hits.Add(1)
+		return entry.originalURL, entry.err
+	}
+	s.misses.Add(1)
+
+	now := time.Now()
+	record, err := s.store.GetRecord(ctx, shortURL)
+	if err == nil {
+		err = record.availability(now)
+	}
+	switch {
+	case err == nil:
+		expiresAt := deadline(now, s.cfg.TTL)
+		if record.ExpiresAt != nil && (expiresAt.IsZero() || record.ExpiresAt.Before(expiresAt)) {
+			expiresAt = *record.ExpiresAt
+		}
+		s.put(gen, shortURL, record.OriginalURL, nil, expiresAt)
+		return record.OriginalURL, nil
+	case errors.Is(err, ErrDeleted), errors.Is(err, ErrExpired):
+		s.put(gen, shortURL, "", err, deadline(now, s.cfg.TTL))
+	case errors.Is(err, ErrNotFound) && s.cfg.NegativeTTL > 0:
+		s.put(gen, shortURL, "", err, deadline(now, s.cfg.NegativeTTL))
+	}
+	return "", err
+}
+
+// deadline возвращает момент устаревания записи с временем жизни ttl;
+// нулевое время означает, что запись не устаревает.
+func deadline(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+func (s *CachedStore) lookup(shortURL string, now time.Time) (cacheEntry, uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[shortURL]
+	if !ok {
+		return cacheEntry{}, s.gen, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+		s.order.Remove(elem)
+		delete(s.entries, shortURL)
+		return cacheEntry{}, s.gen, false
+	}
+	s.order.MoveToFront(elem)
+	return *entry, s.gen, true
+}
+
+func (s *CachedStore) put(gen uint64, shortURL, originalURL string, err error, expiresAt time.Time) {
+	if s.cfg.Size <= 0 {
+		return
+	}
+	entry := &cacheEntry{shortURL: shortURL, originalURL: originalURL, err: err, expiresAt: expiresAt}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if gen != s.gen {
+		return
+	}
+
+	if elem, ok := s.entries[shortURL]; ok {
+		elem.Value = entry
+		s.order.MoveToFront(elem)
+		return
+	}
+	s.entries[shortURL] = s.order.PushFront(entry)
+	for s.order.Len() > s.cfg.Size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*cacheEntry).shortURL)
+		s.evictions.Add(1)
+	}
+}
+
+func (s *CachedStore) invalidate(shortURLs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gen++
+	for _, shortURL := range shortURLs {
+		if elem, ok := s.entries[shortURL]; ok {
+			s.order.Remove(elem)
+			delete(s.entries, shortURL)
+		}
+	}
+}
+
+func (s *CachedStore) AddURL(ctx context.Context, record URLRecord) error {
+	err := s.store.AddURL(ctx, record)
+	s.invalidate(record.ShortURL)
+	return err
+}
+
+func (s *CachedStore) AddURLBatch(ctx context.Context, urls []URLRecord) error {
+	err := s.store.AddURLBatch(ctx, urls)
+	shortURLs := make([]string, 0, len(urls))
+	for _, url := range urls {
+		shortURLs = append(shortURLs, url.ShortURL)
+	}
+	s.invalidate(shortURLs...)
+	return err
+}
+
+func (s *CachedStore) DeleteURLs(ctx context.Context, tasks []DeleteTask) error {
+	err := s.store.DeleteURLs(ctx, tasks)
+	shortURLs := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		shortURLs = append(shortURLs, task.ShortURL)
+	}
+	s.invalidate(shortURLs...)
+	return err
+}
+
+// PurgeExpired очищает кэш целиком, если хранилище что-то удалило:
+// какие именно идентификаторы удалены, оно не сообщает.
+func (s *CachedStore) PurgeExpired(ctx context.Context) (int, error) {
+	purged, err := s.store.PurgeExpired(ctx)
+	if purged > 0 {
+		s.mu.Lock()
+		s.gen++
+		s.entries = make(map[string]*list.Element)
+		s.order.Init()
+		s.mu.Unlock()
+	}
+	return purged, err
+}
+
+func (s *CachedStore) GetRecord(ctx context.Context, shortURL string) (URLRecord, error) {
+	return s.store.GetRecord(ctx, shortURL)
+}
+
+func (s *CachedStore) GetUserURLs(ctx context.Context, userID string) ([]URLRecord, error) {
+	return s.store.GetUserURLs(ctx, userID)
+}
+
+func (s *CachedStore) ScanURLs(ctx context.Context, after string, limit int) ([]URLRecord, error) {
+	return s.store.ScanURLs(ctx, after, limit)
+}
+
+func (s *CachedStore) CountURLs(ctx context.Context) (int, error) {
+	return s.store.CountURLs(ctx)
+}
+
+func (s *CachedStore) Ping(ctx context.Context) error {
+	return s.store.Ping(ctx)
+}
+
+func (s *CachedStore) Close() error {
+	return s.store.Close()
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore считает чтения записей из нижележащего хранилища.
+type countingStore struct {
+	URLStore
+	gets int
+}
+
+func (s *countingStore) GetRecord(ctx context.Context, shortURL string) (URLRecord, error) {
+	s.gets++
+	return s.URLStore.GetRecord(ctx, shortURL)
+}
+
+func TestCachedStore(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingStore{URLStore: InitMemoryStore()}
+	cache := NewCachedStore(backend, CacheConfig{Size: 2, NegativeTTL: time.Minute})
+
+	require.NoError(t, cache.AddURL(ctx, URLRecord{ShortURL: "a", OriginalURL: "https://a.example", UserID: "user"}))
+	for i := 0; i < 3; i++ {
+		got, err := cache.GetURL(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "https://a.example", got)
+	}
+	assert.Equal(t, 1, backend.gets)
+
+	// неизвестный идентификатор кэшируется и сбрасывается при записи
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetURL(ctx, "b")
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	assert.Equal(t, 2, backend.gets)
+	require.NoError(t, cache.AddURLBatch(ctx, []URLRecord{{ShortURL: "b", OriginalURL: "https://b.example"}}))
+	got, err := cache.GetURL(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, "https://b.example", got)
+	assert.Equal(t, 3, backend.gets)
+
+	require.NoError(t, cache.DeleteURLs(ctx, []DeleteTask{{UserID: "user", ShortURL: "a"}}))
+	_, err = cache.GetURL(ctx, "a")
+	assert.ErrorIs(t, err, ErrDeleted)
+	_, err = cache.GetURL(ctx, "a")
+	assert.ErrorIs(t, err, ErrDeleted)
+	assert.Equal(t, 4, backend.gets)
+
+	// третий идентификатор вытесняет давно не использованный "b"
+	require.NoError(t, cache.AddURL(ctx, URLRecord{ShortURL: "c", OriginalURL: "https://c.example"}))
+	_, err = cache.GetURL(ctx, "c")
+	require.NoError(t, err)
+	_, err = cache.GetURL(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, 6, backend.gets)
+
+	assert.Equal(t, CacheStats{Hits: 4, Misses: 6, Evictions: 2}, cache.Stats())
+}
+
+func TestCachedStoreTTL(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingStore{URLStore: InitMemoryStore()}
+	cache := NewCachedStore(backend, CacheConfig{Size: 10, TTL: 10 * time.Millisecond})
+
+	require.NoError(t, cache.AddURL(ctx, URLRecord{ShortURL: "a", OriginalURL: "https://a.example"}))
+	_, err := cache.GetURL(ctx, "a")
+	require.NoError(t, err)
+	_, err = cache.GetURL(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, 1, backend.gets)
+
+	time.Sleep(20 * time.Millisecond)
+	_, err = cache.GetURL(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, backend.gets)
+
+	// без NegativeTTL неизвестные идентификаторы не кэшируются
+	for i := 0; i < 2; i++ {
+		_, err = cache.GetURL(ctx, "missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	assert.Equal(t, 4, backend.gets)
+}
+
+func TestCachedStoreLinkExpiry(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingStore{URLStore: InitMemoryStore()}
+	// без TTL запись живёт в кэше до вытеснения, но не дольше самой ссылки
+	cache := NewCachedStore(backend, CacheConfig{Size: 10})
+
+	expiresAt := time.Now().Add(50 * time.Millisecond)
+	require.NoError(t, cache.AddURL(ctx, URLRecord{ShortURL: "a", OriginalURL: "https://a.example", ExpiresAt: &expiresAt}))
+	for i := 0; i < 2; i++ {
+		got, err := cache.GetURL(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "https://a.example", got)
+	}
+	assert.Equal(t, 1, backend.gets)
+
+	time.Sleep(time.Until(expiresAt) + 10*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		got, err := cache.GetURL(ctx, "a")
+		assert.ErrorIs(t, err, ErrExpired)
+		assert.Empty(t, got)
+	}
+	assert.Equal(t, 2, backend.gets)
+}
+
+func TestCachedStoreConcurrentAccess(t *testing.T) {
+	stressStore(t, NewCachedStore(InitMemoryStore(), CacheConfig{Size: 100, NegativeTTL: time.Minute}))
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+)
+
+const DefaultCopyBatchSize = 500
+
+type CopyOptions struct {
+	BatchSize int
+	// Resume — прогресс прерванного запуска: копирование продолжается после
+	// Resume.Last, а счётчики продолжают его значения.
+	Resume CopyProgress
+	// Progress вызывается после каждой перенесённой пачки.
+	Progress func(CopyProgress)
+}
+
+type CopyProgress struct {
+	Last      string `json:"last"`      // последний обработанный идентификатор
+	Copied    int    `json:"copied"`    // записи, добавленные в приёмник
+	Skipped   int    `json:"skipped"`   // записи, уже имевшиеся в приёмнике с той же ссылкой
+	Conflicts int    `json:"conflicts"` // идентификаторы, занятые в приёмнике другой ссылкой
+}
+
+// Processed возвращает число записей источника, обработанных к этому моменту.
+func (p CopyProgress) Processed() int {
+	return p.Copied + p.Skipped + p.Conflicts
+}
+
+// CopyURLs переносит все записи из src в dst пачками в порядке ScanURLs.
+// Пачка, которую приёмник отверг из-за конфликта, переносится по одной
+// записи, чтобы отделить уже перенесённые записи от настоящих конфликтов.
+func CopyURLs(ctx context.Context, src, dst URLStore, opts CopyOptions) (CopyProgress, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultCopyBatchSize
+	}
+
+	progress := opts.Resume
+	for {
+		records, err := src.ScanURLs(ctx, progress.Last, opts.BatchSize)
+		if err != nil {
+			return progress, err
+		}
+		if len(records) == 0 {
+			return progress, nil
+		}
+
+		err = dst.AddURLBatch(ctx, records)
+		switch {
+		case err == nil:
+			progress.Copied += len(records)
+		case errors.Is(err, ErrConflict):
+			for _, record := range records {
+				if err := copyRecord(ctx, dst, record, &progress); err != nil {
+					return progress, err
+				}
+			}
+		default:
+			return progress, err
+		}
+
+		progress.Last = records[len(records)-1].ShortURL
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+}
+
+func copyRecord(ctx context.Context, dst URLStore, record URLRecord, progress *CopyProgress) error {
+	err := dst.AddURL(ctx, record)
+	if err == nil {
+		progress.Copied++
+		return nil
+	}
+	if !errors.Is(err, ErrConflict) {
+		return err
+	}
+
+	existing, err := dst.GetURL(ctx, record.ShortURL)
+	if err == nil && existing == record.OriginalURL {
+		progress.Skipped++
+		return nil
+	}
+	// удалённую или просроченную запись приёмник не раскрывает; если источник
+	// в том же состоянии, считаем её перенесённой при прошлом запуске
+	if (errors.Is(err, ErrDeleted) || errors.Is(err, ErrExpired)) && record.availability(time.Now()) != nil {
+		progress.Skipped++
+		return nil
+	}
+	progress.Conflicts++
+	logger.Log.Warnw("Short URL is taken in the target storage",
+		"short_url", record.ShortURL, "original_url", record.OriginalURL, "target_url", existing)
+	return nil
+}
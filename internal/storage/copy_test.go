@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyURLs(t *testing.T) {
+	ctx := context.Background()
+	src, err := InitFileStore(filepath.Join(t.TempDir(), "urls.json"))
+	require.NoError(t, err)
+	defer src.Close()
+
+	var records []URLRecord
+	for i := 0; i < 25; i++ {
+		records = append(records, URLRecord{
+			ShortURL:    fmt.Sprintf("id%02d", i),
+			OriginalURL: fmt.Sprintf("https://example.com/%d", i),
+			UserID:      "user",
+		})
+	}
+	require.NoError(t, src.AddURLBatch(ctx, records))
+	require.NoError(t, src.DeleteURLs(ctx, []DeleteTask{{UserID: "user", ShortURL: "id03"}}))
+
+	dst, _ := initTestBoltStore(t)
+	defer dst.Close()
+	require.NoError(t, dst.AddURL(ctx, URLRecord{ShortURL: "id20", OriginalURL: "https://example.org/taken"}))
+
+	// первый запуск прерывается после двух пачек
+	var batches []CopyProgress
+	stop := fmt.Errorf("interrupted")
+	progress, err := CopyURLs(ctx, src, &failingStore{URLStore: dst, after: 2, err: stop}, CopyOptions{
+		BatchSize: 10,
+		Progress:  func(p CopyProgress) { batches = append(batches, p) },
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, "id19", progress.Last)
+	assert.Len(t, batches, 2)
+
+	// повторный запуск продолжает с сохранённого места и переносит остаток
+	progress, err = CopyURLs(ctx, src, dst, CopyOptions{BatchSize: 10, Resume: progress})
+	require.NoError(t, err)
+	assert.Equal(t, CopyProgress{Last: "id24", Copied: 24, Conflicts: 1}, progress)
+	assert.Equal(t, 25, progress.Processed())
+
+	// полный перезапуск ничего не дублирует
+	progress, err = CopyURLs(ctx, src, dst, CopyOptions{BatchSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, CopyProgress{Last: "id24", Skipped: 24, Conflicts: 1}, progress)
+
+	n, err := dst.CountURLs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 25, n)
+	_, err = dst.GetURL(ctx, "id03")
+	assert.ErrorIs(t, err, ErrDeleted)
+	got, err := dst.GetURL(ctx, "id20")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org/taken", got)
+}
+
+// failingStore пропускает заданное число пачек и затем возвращает ошибку.
+type failingStore struct {
+	URLStore
+	after int
+	err   error
+}
+
+func (s *failingStore) AddURLBatch(ctx context.Context, urls []URLRecord) error {
+	if s.after == 0 {
+		return s.err
+	}
+	s.after--
+	return s.URLStore.AddURLBatch(ctx, urls)
+}
@@ -2,105 +2,576 @@ package storage
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"go.uber.org/zap"
 )
 
+// SyncPolicy определяет, когда FileStore сбрасывает записи на диск.
+type SyncPolicy string
+
+const (
+	SyncAlways   SyncPolicy = "always"
+	SyncInterval SyncPolicy = "interval"
+	SyncNever    SyncPolicy = "never"
+)
+
+const DefaultSyncInterval = time.Second
+
+// compactMinLines — размер лога, до которого устаревшие строки не вычищаются.
+const compactMinLines = 1000
+
+var (
+	ErrInvalidSyncPolicy = errors.New("invalid sync policy")
+	errChecksum          = errors.New("checksum mismatch")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch policy := SyncPolicy(s); policy {
+	case SyncAlways, SyncInterval, SyncNever:
+		return policy, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidSyncPolicy, s)
+}
+
+type FileStoreOption func(*FileStore)
+
+// WithSyncPolicy задаёт политику fsync; interval используется только с SyncInterval.
+func WithSyncPolicy(policy SyncPolicy, interval time.Duration) FileStoreOption {
+	return func(s *FileStore) {
+		s.syncPolicy = policy
+		if interval > 0 {
+			s.syncInterval = interval
+		}
+	}
+}
+
+// FileStore хранит ссылки в памяти и дописывает каждое изменение в файл
+// в формате JSON Lines. Все операции сериализуются мьютексом, а каждая
+// запись попадает в файл одним вызовом Write, поэтому строки не перемешиваются.
+//
+// Каждая строка заканчивается табуляцией и CRC32 записи, что позволяет
+// при запуске отличить повреждённую запись от целой. Строки без контрольной
+// суммы, записанные прежними версиями, принимаются без проверки.
 type FileStore struct {
+	mu     sync.RWMutex
+	path   string
 	file   *os.File
-	urlMap map[string]string
+	closed bool
+	urlMap map[string]URLRecord
+	index  scanIndex
 	nextID int
+	size   int64 // длина лога в байтах
+	lines  int   // число строк в логе, включая устаревшие версии записей
+
+	compactMu sync.Mutex
+
+	syncPolicy   SyncPolicy
+	syncInterval time.Duration
+	dirty        atomic.Bool
+	stopSync     chan struct{}
+	syncDone     chan struct{}
 }
 
-func InitFileStore(filePath string) (*FileStore, error) {
+func InitFileStore(filePath string, opts ...FileStoreOption) (*FileStore, error) {
 	store := &FileStore{
-		urlMap: make(map[string]string),
-		nextID: 1,
+		path:         filePath,
+		urlMap:       make(map[string]URLRecord),
+		nextID:       1,
+		syncPolicy:   SyncInterval,
+		syncInterval: DefaultSyncInterval,
+	}
+	for _, opt := range opts {
+		opt(store)
+	}
+	if _, err := ParseSyncPolicy(string(store.syncPolicy)); err != nil {
+		return nil, err
 	}
 
 	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
 	if err != nil {
 		return nil, err
 	}
+	store.file = file
+
+	if err := store.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
 
+	if store.syncPolicy == SyncInterval {
+		store.stopSync = make(chan struct{})
+		store.syncDone = make(chan struct{})
+		go store.syncLoop()
+	}
+	return store, nil
+}
+
+// load читает лог и восстанавливает состояние. Повреждённые строки внутри
+// файла пропускаются, а оборванная последняя запись (сбой посреди записи)
+// отрезается, чтобы следующие записи начинались с новой строки.
+func (s *FileStore) load() error {
+	reader := bufio.NewReader(s.file)
+	var offset int64
 	maxID := 0
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		var record URLRecord
-		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
-			return nil, err
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if len(line) == 0 {
+			break
+		}
+
+		complete := line[len(line)-1] == '\n'
+		record, decodeErr := decodeLine(bytes.TrimSuffix(line, []byte{'\n'}))
+		if !complete {
+			if decodeErr != nil {
+				logger.Log.Warnw("Truncating torn record at the end of the storage file",
+					"path", s.path, "offset", offset, zap.Error(decodeErr))
+				if err := s.file.Truncate(offset); err != nil {
+					return err
+				}
+				break
+			}
+			if _, err := s.file.Write([]byte{'\n'}); err != nil {
+				return err
+			}
+			line = append(line, '\n')
+		}
+
+		offset += int64(len(line))
+		s.lines++
+		if decodeErr != nil {
+			logger.Log.Warnw("Skipping corrupted record in the storage file",
+				"path", s.path, "offset", offset-int64(len(line)), zap.Error(decodeErr))
+			continue
 		}
 
 		if record.UUID > maxID {
 			maxID = record.UUID
 		}
-		store.urlMap[record.ShortURL] = record.OriginalURL
+		s.urlMap[record.ShortURL] = record
 	}
 
-	if err := scanner.Err(); err != nil {
+	s.size = offset
+	s.nextID = maxID + 1
+	return nil
+}
+
+// encodeLine кодирует запись в строку лога: JSON, табуляция, CRC32 в hex.
+func encodeLine(record URLRecord) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
 		return nil, err
 	}
+	line := make([]byte, 0, len(data)+10)
+	line = append(line, data...)
+	return fmt.Appendf(line, "\t%08x\n", crc32.Checksum(data, crcTable)), nil
+}
 
-	store.nextID = maxID + 1
-	store.file = file
+func decodeLine(line []byte) (URLRecord, error) {
+	var record URLRecord
+	data := line
+	if i := bytes.LastIndexByte(line, '\t'); i >= 0 {
+		data = line[:i]
+		sum, err := strconv.ParseUint(string(line[i+1:]), 16, 32)
+		if err != nil || crc32.Checksum(data, crcTable) != uint32(sum) {
+			return record, errChecksum
+		}
+	}
+	err := json.Unmarshal(data, &record)
+	return record, err
+}
 
-	return store, nil
+func (s *FileStore) AddURL(ctx context.Context, record URLRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, exists := s.urlMap[record.ShortURL]; exists {
+		if existing.OriginalURL != record.OriginalURL {
+			logger.Log.Warnf("short URL already exists for another URL: %s", record.ShortURL)
+			return ErrConflict
+		}
+		return nil
+	}
+
+	record.UUID = s.nextID
+	if err := s.writeRecords(record); err != nil {
+		return err
+	}
+
+	s.urlMap[record.ShortURL] = record
+	s.index.invalidate()
+	s.nextID++
+	return nil
+}
+
+// writeRecords дописывает записи в файл одним вызовом Write.
+// Вызывающий должен держать s.mu.
+func (s *FileStore) writeRecords(records ...URLRecord) error {
+	if s.closed {
+		return os.ErrClosed
+	}
+
+	var buf []byte
+	for _, record := range records {
+		line, err := encodeLine(record)
+		if err != nil {
+			logger.Log.Errorf("error marshaling JSON: %w", err)
+			return err
+		}
+		buf = append(buf, line...)
+	}
+
+	if n, err := s.file.Write(buf); err != nil {
+		// отрезаем недописанный хвост, чтобы следующая запись начиналась с новой строки
+		if n > 0 {
+			s.file.Truncate(s.size)
+		}
+		logger.Log.Errorf("error writing to file: %w", err)
+		return err
+	}
+	s.size += int64(len(buf))
+	s.lines += len(records)
+
+	switch s.syncPolicy {
+	case SyncAlways:
+		return s.file.Sync()
+	case SyncInterval:
+		s.dirty.Store(true)
+	}
+	return nil
 }
 
-func (s *FileStore) AddURL(ctx context.Context, originalURL, shortURL string) error {
-	if _, exists := s.urlMap[shortURL]; exists {
-		logger.Log.Warnf("short URL already exists: %s", shortURL)
+func (s *FileStore) syncLoop() {
+	defer close(s.syncDone)
+
+	ticker := time.NewTicker(s.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !s.dirty.Swap(false) {
+				continue
+			}
+			s.mu.RLock()
+			err := s.file.Sync()
+			s.mu.RUnlock()
+			if err != nil {
+				s.dirty.Store(true)
+				logger.Log.Error("Failed to sync storage file", zap.Error(err))
+			}
+		case <-s.stopSync:
+			return
+		}
+	}
+}
+
+func (s *FileStore) GetURL(ctx context.Context, shortURL string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.urlMap[shortURL]
+	if !ok {
+		return "", ErrNotFound
+	}
+	if err := record.availability(time.Now()); err != nil {
+		return "", err
+	}
+	return record.OriginalURL, nil
+}
+
+func (s *FileStore) GetRecord(ctx context.Context, shortURL string) (URLRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.urlMap[shortURL]
+	if !ok {
+		return URLRecord{}, ErrNotFound
+	}
+	return record, nil
+}
+
+func (s *FileStore) GetUserURLs(ctx context.Context, userID string) ([]URLRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var records []URLRecord
+	for _, record := range s.urlMap {
+		if record.UserID == userID && record.availability(now) == nil {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// DeleteURLs дописывает в лог новую версию записи с флагом удаления:
+// при чтении файла более поздняя запись заменяет предыдущую.
+func (s *FileStore) DeleteURLs(ctx context.Context, tasks []DeleteTask) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted []URLRecord
+	for _, task := range tasks {
+		record, exists := s.urlMap[task.ShortURL]
+		if !exists || record.UserID != task.UserID || record.DeletedFlag {
+			continue
+		}
+		record.DeletedFlag = true
+		deleted = append(deleted, record)
+	}
+	if len(deleted) == 0 {
 		return nil
 	}
 
-	s.urlMap[shortURL] = originalURL
+	if err := s.writeRecords(deleted...); err != nil {
+		return err
+	}
+	for _, record := range deleted {
+		s.urlMap[record.ShortURL] = record
+	}
+	return nil
+}
+
+// PurgeExpired удаляет просроченные записи и сжимает лог, если из него
+// что-то удалено или устаревших строк в нём больше, чем актуальных.
+func (s *FileStore) PurgeExpired(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	now := time.Now()
+	purged := 0
+	for shortURL, record := range s.urlMap {
+		if record.IsExpired(now) {
+			delete(s.urlMap, shortURL)
+			purged++
+		}
+	}
+	if purged > 0 {
+		s.index.invalidate()
+	}
+	bloated := s.lines > compactMinLines && s.lines > 2*len(s.urlMap)
+	s.mu.Unlock()
 
-	record := URLRecord{
-		UUID:        s.nextID,
-		ShortURL:    shortURL,
-		OriginalURL: originalURL,
+	if purged == 0 && !bloated {
+		return 0, nil
 	}
+	return purged, s.Compact(ctx)
+}
 
-	data, err := json.Marshal(record)
+// Compact переписывает лог в новый снимок без удалённых и просроченных
+// записей и атомарно подменяет им основной файл. Снимок пишется без
+// блокировки хранилища; записи, появившиеся за это время, переносятся
+// из хвоста старого лога под блокировкой непосредственно перед подменой.
+func (s *FileStore) Compact(ctx context.Context) error {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return os.ErrClosed
+	}
+	now := time.Now()
+	records := make([]URLRecord, 0, len(s.urlMap))
+	dropped := make(map[string]URLRecord)
+	for shortURL, record := range s.urlMap {
+		if record.availability(now) != nil {
+			dropped[shortURL] = record
+			continue
+		}
+		records = append(records, record)
+	}
+	offset, lines := s.size, s.lines
+	s.mu.RUnlock()
+
+	sort.Slice(records, func(i, j int) bool { return records[i].UUID < records[j].UUID })
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
 	if err != nil {
-		logger.Log.Errorf("error marshaling JSON: %w", err)
 		return err
 	}
+	defer os.Remove(tmp.Name())
 
-	if _, err := s.file.Write(append(data, '\n')); err != nil {
-		logger.Log.Errorf("error writing to file: %w", err)
+	w := bufio.NewWriter(tmp)
+	var written int64
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			tmp.Close()
+			return err
+		}
+		line, err := encodeLine(record)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if _, err := w.Write(line); err != nil {
+			tmp.Close()
+			return err
+		}
+		written += int64(len(line))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
 		return err
 	}
 
-	s.nextID++
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		tmp.Close()
+		return os.ErrClosed
+	}
+
+	tail, err := io.Copy(tmp, io.NewSectionReader(s.file, offset, s.size-offset))
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(s.path)); err != nil {
+		logger.Log.Warn("Failed to sync storage directory", zap.Error(err))
+	}
+
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = file
+
+	// из памяти убираются только записи, не изменившиеся с момента снимка
+	for shortURL, record := range dropped {
+		if current, ok := s.urlMap[shortURL]; ok && current == record {
+			delete(s.urlMap, shortURL)
+		}
+	}
+	s.index.invalidate()
+	s.size = written + tail
+	s.lines = len(records) + s.lines - lines
+	logger.Log.Infow("Compacted storage file", "path", s.path, "records", len(records), "dropped", len(dropped))
 	return nil
 }
 
-func (s *FileStore) GetURL(ctx context.Context, shortURL string) (string, bool) {
-	url, ok := s.urlMap[shortURL]
-	return url, ok
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (s *FileStore) CountURLs(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.urlMap), nil
+}
+
+func (s *FileStore) ScanURLs(ctx context.Context, after string, limit int) ([]URLRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.index.page(s.urlMap, after, limit), nil
 }
 
 func (s *FileStore) Close() error {
-	if s.file != nil {
-		return s.file.Close()
+	if s.stopSync != nil {
+		close(s.stopSync)
+		<-s.syncDone
+		s.stopSync = nil
 	}
-	return nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.syncPolicy != SyncNever {
+		if err := s.file.Sync(); err != nil {
+			s.file.Close()
+			return err
+		}
+	}
+	return s.file.Close()
 }
 
 func (s *FileStore) Ping(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return os.ErrClosed
+	}
 	_, err := s.file.Stat()
 	return err
 }
 
+// AddURLBatch записывает пачку целиком либо не записывает ничего,
+// если хотя бы один идентификатор занят другой ссылкой.
 func (s *FileStore) AddURLBatch(ctx context.Context, urls []URLRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := make(map[string]string, len(urls))
+	toAdd := make([]URLRecord, 0, len(urls))
 	for _, url := range urls {
-		if err := s.AddURL(ctx, url.OriginalURL, url.ShortURL); err != nil {
-			return err
+		original, exists := batch[url.ShortURL]
+		if !exists {
+			if existing, ok := s.urlMap[url.ShortURL]; ok {
+				original, exists = existing.OriginalURL, true
+			}
 		}
+		if exists {
+			if original != url.OriginalURL {
+				return ErrConflict
+			}
+			continue
+		}
+		url.UUID = s.nextID + len(toAdd)
+		batch[url.ShortURL] = url.OriginalURL
+		toAdd = append(toAdd, url)
+	}
+	if len(toAdd) == 0 {
+		return nil
+	}
+
+	if err := s.writeRecords(toAdd...); err != nil {
+		return err
+	}
+	for _, url := range toAdd {
+		s.urlMap[url.ShortURL] = url
 	}
+	s.index.invalidate()
+	s.nextID += len(toAdd)
 	return nil
 }
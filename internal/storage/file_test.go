@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodedLine(t *testing.T, record URLRecord) string {
+	line, err := encodeLine(record)
+	require.NoError(t, err)
+	return string(line)
+}
+
+func TestFileStoreRecovery(t *testing.T) {
+	ctx := context.Background()
+	first := encodedLine(t, URLRecord{UUID: 1, ShortURL: "first", OriginalURL: "https://example.com/1"})
+	corrupted := strings.Replace(encodedLine(t, URLRecord{UUID: 2, ShortURL: "second", OriginalURL: "https://example.com/2"}), "example", "exampel", 1)
+	legacy := `{"uuid":3,"short_url":"legacy","original_url":"https://example.com/3"}` + "\n"
+	torn := encodedLine(t, URLRecord{UUID: 4, ShortURL: "torn", OriginalURL: "https://example.com/4"})
+	torn = torn[:len(torn)/2]
+
+	path := filepath.Join(t.TempDir(), "urls.json")
+	require.NoError(t, os.WriteFile(path, []byte(first+corrupted+legacy+torn), 0644))
+
+	store, err := InitFileStore(path)
+	require.NoError(t, err)
+
+	got, err := store.GetURL(ctx, "first")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", got)
+	got, err = store.GetURL(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/3", got)
+	_, err = store.GetURL(ctx, "second")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.GetURL(ctx, "torn")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, first+corrupted+legacy, string(data), "torn tail must be truncated")
+
+	require.NoError(t, store.AddURL(ctx, URLRecord{ShortURL: "after", OriginalURL: "https://example.com/5"}))
+	require.NoError(t, store.Close())
+
+	reopened, err := InitFileStore(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	got, err = reopened.GetURL(ctx, "after")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/5", got)
+}
+
+func TestFileStoreKeepsCompleteTailWithoutNewline(t *testing.T) {
+	line := encodedLine(t, URLRecord{UUID: 1, ShortURL: "first", OriginalURL: "https://example.com/1"})
+	path := filepath.Join(t.TempDir(), "urls.json")
+	require.NoError(t, os.WriteFile(path, []byte(strings.TrimSuffix(line, "\n")), 0644))
+
+	store, err := InitFileStore(path, WithSyncPolicy(SyncAlways, 0))
+	require.NoError(t, err)
+	require.NoError(t, store.AddURL(context.Background(), URLRecord{ShortURL: "second", OriginalURL: "https://example.com/2"}))
+	require.NoError(t, store.Close())
+
+	reopened, err := InitFileStore(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	n, err := reopened.CountURLs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestFileStoreCompact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+	store, err := InitFileStore(path, WithSyncPolicy(SyncNever, 0))
+	require.NoError(t, err)
+
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, store.AddURLBatch(ctx, []URLRecord{
+		{ShortURL: "kept", OriginalURL: "https://example.com/kept", UserID: "user"},
+		{ShortURL: "deleted", OriginalURL: "https://example.com/deleted", UserID: "user"},
+		{ShortURL: "expired", OriginalURL: "https://example.com/expired", ExpiresAt: &past},
+	}))
+	require.NoError(t, store.DeleteURLs(ctx, []DeleteTask{{UserID: "user", ShortURL: "deleted"}}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, store.AddURL(ctx, URLRecord{ShortURL: "concurrent", OriginalURL: "https://example.com/concurrent"}))
+	}()
+	require.NoError(t, store.Compact(ctx))
+	wg.Wait()
+
+	n, err := store.CountURLs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	require.NoError(t, store.AddURL(ctx, URLRecord{ShortURL: "later", OriginalURL: "https://example.com/later"}))
+	require.NoError(t, store.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(data), "\n"))
+	assert.NotContains(t, string(data), "deleted")
+	assert.NotContains(t, string(data), "expired")
+
+	reopened, err := InitFileStore(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	for _, id := range []string{"kept", "concurrent", "later"} {
+		_, err := reopened.GetURL(ctx, id)
+		assert.NoError(t, err, id)
+	}
+	require.NoError(t, reopened.AddURL(ctx, URLRecord{ShortURL: "next", OriginalURL: "https://example.com/next"}))
+	records, err := reopened.GetUserURLs(ctx, "")
+	require.NoError(t, err)
+	ids := make(map[int]bool)
+	for _, record := range records {
+		assert.False(t, ids[record.UUID], "duplicate UUID %d", record.UUID)
+		ids[record.UUID] = true
+	}
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	for _, s := range []string{"always", "interval", "never"} {
+		policy, err := ParseSyncPolicy(s)
+		require.NoError(t, err)
+		assert.Equal(t, SyncPolicy(s), policy)
+	}
+	_, err := ParseSyncPolicy("sometimes")
+	assert.ErrorIs(t, err, ErrInvalidSyncPolicy)
+}
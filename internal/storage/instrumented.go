@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/metrics"
+)
+
+// InstrumentedStore замеряет длительность и ошибки операций над хранилищем.
+type InstrumentedStore struct {
+	store   URLStore
+	backend string
+}
+
+func Instrument(store URLStore, backend string) *InstrumentedStore {
+	return &InstrumentedStore{store: store, backend: backend}
+}
+
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrConflict):
+		return "conflict"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrDeleted):
+		return "deleted"
+	case errors.Is(err, ErrExpired):
+		return "expired"
+	}
+	return "internal"
+}
+
+func (s *InstrumentedStore) observe(operation string, start time.Time, err error) {
+	metrics.StorageOperationDuration.Observe(time.Since(start).Seconds(), s.backend, operation)
+	if err != nil {
+		metrics.StorageErrors.Inc(s.backend, operation, errorKind(err))
+	}
+}
+
+func (s *InstrumentedStore) AddURL(ctx context.Context, record URLRecord) error {
+	start := time.Now()
+	err := s.store.AddURL(ctx, record)
+	s.observe("add_url", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) AddURLBatch(ctx context.Context, urls []URLRecord) error {
+	start := time.Now()
+	err := s.store.AddURLBatch(ctx, urls)
+	s.observe("add_url_batch", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetURL(ctx context.Context, shortURL string) (string, error) {
+	start := time.Now()
+	originalURL, err := s.store.GetURL(ctx, shortURL)
+	s.observe("get_url", start, err)
+	return originalURL, err
+}
+
+func (s *InstrumentedStore) GetRecord(ctx context.Context, shortURL string) (URLRecord, error) {
+	start := time.Now()
+	record, err := s.store.GetRecord(ctx, shortURL)
+	s.observe("get_record", start, err)
+	return record, err
+}
+
+func (s *InstrumentedStore) GetUserURLs(ctx context.Context, userID string) ([]URLRecord, error) {
+	start := time.Now()
+	records, err := s.store.GetUserURLs(ctx, userID)
+	s.observe("get_user_urls", start, err)
+	return records, err
+}
+
+func (s *InstrumentedStore) DeleteURLs(ctx context.Context, tasks []DeleteTask) error {
+	start := time.Now()
+	err := s.store.DeleteURLs(ctx, tasks)
+	s.observe("delete_urls", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) ScanURLs(ctx context.Context, after string, limit int) ([]URLRecord, error) {
+	start := time.Now()
+	records, err := s.store.ScanURLs(ctx, after, limit)
+	s.observe("scan_urls", start, err)
+	return records, err
+}
+
+func (s *InstrumentedStore) PurgeExpired(ctx context.Context) (int, error) {
+	start := time.Now()
+	purged, err := s.store.PurgeExpired(ctx)
+	s.observe("purge_expired", start, err)
+	return purged, err
+}
+
+func (s *InstrumentedStore) CountURLs(ctx context.Context) (int, error) {
+	start := time.Now()
+	n, err := s.store.CountURLs(ctx)
+	s.observe("count_urls", start, err)
+	return n, err
+}
+
+func (s *InstrumentedStore) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := s.store.Ping(ctx)
+	s.observe("ping", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) Close() error {
+	return s.store.Close()
+}
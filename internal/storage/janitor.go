@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Janitor периодически удаляет из хранилища ссылки с истёкшим сроком жизни.
+type Janitor struct {
+	store    URLStore
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewJanitor запускает очистку с заданным интервалом. Неположительный
+// интервал отключает очистку.
+func NewJanitor(store URLStore, interval time.Duration) *Janitor {
+	j := &Janitor{
+		store:    store,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if interval <= 0 {
+		close(j.done)
+		return j
+	}
+	go j.run()
+	return j
+}
+
+func (j *Janitor) run() {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			purged, err := j.store.PurgeExpired(context.Background())
+			if err != nil {
+				logger.Log.Error("Failed to purge expired URLs", zap.Error(err))
+				continue
+			}
+			if purged > 0 {
+				logger.Log.Infow("Purged expired URLs", "count", purged)
+			}
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+// Close останавливает Janitor и ждёт завершения текущей очистки.
+func (j *Janitor) Close() {
+	close(j.stop)
+	<-j.done
+}
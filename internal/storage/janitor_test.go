@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJanitor(t *testing.T) {
+	ctx := context.Background()
+	store := InitMemoryStore()
+	expired := time.Now().Add(-time.Minute)
+	require.NoError(t, store.AddURL(ctx, URLRecord{ShortURL: "old", OriginalURL: "https://example.com/", ExpiresAt: &expired}))
+
+	// нулевой интервал отключает очистку и не роняет сервис
+	NewJanitor(store, 0).Close()
+	n, err := store.CountURLs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	janitor := NewJanitor(store, 10*time.Millisecond)
+	defer janitor.Close()
+	assert.Eventually(t, func() bool {
+		n, err := store.CountURLs(ctx)
+		return err == nil && n == 0
+	}, 3*time.Second, 10*time.Millisecond)
+}
@@ -1,25 +1,165 @@
 package storage
 
-import "context"
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
 
 type MemoryStore struct {
-	urlMap map[string]string
+	mu     sync.RWMutex
+	urlMap map[string]URLRecord
+	index  scanIndex
 }
 
 func InitMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		urlMap: make(map[string]string),
+		urlMap: make(map[string]URLRecord),
 	}
 }
 
-func (s *MemoryStore) AddURL(ctx context.Context, originalURL, shortURL string) error {
-	s.urlMap[shortURL] = originalURL
+func (s *MemoryStore) AddURL(ctx context.Context, record URLRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, exists := s.urlMap[record.ShortURL]; exists {
+		if existing.OriginalURL != record.OriginalURL {
+			return ErrConflict
+		}
+		return nil
+	}
+	s.urlMap[record.ShortURL] = record
+	s.index.invalidate()
 	return nil
 }
 
-func (s *MemoryStore) GetURL(ctx context.Context, shortURL string) (string, bool) {
-	originalURL, exists := s.urlMap[shortURL]
-	return originalURL, exists
+func (s *MemoryStore) GetURL(ctx context.Context, shortURL string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, exists := s.urlMap[shortURL]
+	if !exists {
+		return "", ErrNotFound
+	}
+	if err := record.availability(time.Now()); err != nil {
+		return "", err
+	}
+	return record.OriginalURL, nil
+}
+
+func (s *MemoryStore) GetRecord(ctx context.Context, shortURL string) (URLRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, exists := s.urlMap[shortURL]
+	if !exists {
+		return URLRecord{}, ErrNotFound
+	}
+	return record, nil
+}
+
+func (s *MemoryStore) GetUserURLs(ctx context.Context, userID string) ([]URLRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var records []URLRecord
+	for _, record := range s.urlMap {
+		if record.UserID == userID && record.availability(now) == nil {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (s *MemoryStore) DeleteURLs(ctx context.Context, tasks []DeleteTask) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, task := range tasks {
+		record, exists := s.urlMap[task.ShortURL]
+		if !exists || record.UserID != task.UserID {
+			continue
+		}
+		record.DeletedFlag = true
+		s.urlMap[task.ShortURL] = record
+	}
+	return nil
+}
+
+func (s *MemoryStore) PurgeExpired(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	purged := 0
+	for shortURL, record := range s.urlMap {
+		if record.IsExpired(now) {
+			delete(s.urlMap, shortURL)
+			purged++
+		}
+	}
+	if purged > 0 {
+		s.index.invalidate()
+	}
+	return purged, nil
+}
+
+func (s *MemoryStore) CountURLs(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.urlMap), nil
+}
+
+func (s *MemoryStore) ScanURLs(ctx context.Context, after string, limit int) ([]URLRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.index.page(s.urlMap, after, limit), nil
+}
+
+// scanIndex хранит отсортированные ключи карты для постраничного ScanURLs,
+// чтобы каждая страница не сортировала всю карту заново. Индекс
+// перестраивается лениво после добавления или удаления ключей.
+type scanIndex struct {
+	mu   sync.Mutex
+	keys []string // nil — индекс нужно перестроить
+}
+
+// invalidate сбрасывает индекс. Вызывается под блокировкой записи хранилища.
+func (x *scanIndex) invalidate() {
+	x.mu.Lock()
+	x.keys = nil
+	x.mu.Unlock()
+}
+
+// page выбирает страницу записей после after. Вызывается как минимум под
+// блокировкой чтения хранилища, поэтому карта во время выборки не меняется.
+func (x *scanIndex) page(urlMap map[string]URLRecord, after string, limit int) []URLRecord {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.keys == nil {
+		x.keys = make([]string, 0, len(urlMap))
+		for shortURL := range urlMap {
+			x.keys = append(x.keys, shortURL)
+		}
+		sort.Strings(x.keys)
+	}
+
+	i := sort.SearchStrings(x.keys, after)
+	if i < len(x.keys) && x.keys[i] == after {
+		i++
+	}
+	var records []URLRecord
+	for ; i < len(x.keys) && len(records) < limit; i++ {
+		if record, ok := urlMap[x.keys[i]]; ok {
+			records = append(records, record)
+		}
+	}
+	return records
 }
 
 func (s *MemoryStore) Ping(ctx context.Context) error {
@@ -31,8 +171,24 @@ func (s *MemoryStore) Close() error {
 }
 
 func (s *MemoryStore) AddURLBatch(ctx context.Context, urls []URLRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := make(map[string]string, len(urls))
+	for _, url := range urls {
+		if existing, exists := s.urlMap[url.ShortURL]; exists && existing.OriginalURL != url.OriginalURL {
+			return ErrConflict
+		}
+		if original, exists := batch[url.ShortURL]; exists && original != url.OriginalURL {
+			return ErrConflict
+		}
+		batch[url.ShortURL] = url.OriginalURL
+	}
 	for _, url := range urls {
-		s.urlMap[url.ShortURL] = url.OriginalURL
+		if _, exists := s.urlMap[url.ShortURL]; !exists {
+			s.urlMap[url.ShortURL] = url
+			s.index.invalidate()
+		}
 	}
 	return nil
 }
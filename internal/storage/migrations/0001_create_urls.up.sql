@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS urls (
+    id SERIAL PRIMARY KEY,
+    original_url TEXT NOT NULL,
+    short_url TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS short_url_idx ON urls (short_url);
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -15,54 +16,160 @@ type PostgresStore struct {
 	db *sql.DB
 }
 
+// InitPostgresStore подключается к базе и применяет недостающие миграции схемы.
 func InitPostgresStore(dsn string) (*PostgresStore, error) {
 	db, err := sql.Open("pgx", dsn)
 	if err != nil {
-		logger.Log.Fatal("Failed to connect to database: ", err)
+		return nil, err
 	}
-	tx, err := db.Begin()
 
+	migrator, err := NewMigrator(db)
 	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	tx.Exec(`CREATE TABLE IF NOT EXISTS urls (
-        id SERIAL PRIMARY KEY,
-        original_url TEXT NOT NULL,
-        short_url TEXT NOT NULL UNIQUE
-    );`)
+	logger.Log.Info("Database initalized successfully")
+	s := &PostgresStore{db: db}
+	return s, nil
+}
+
+const insertURL = "INSERT INTO urls (original_url, short_url, user_id, is_deleted, expires_at) VALUES ($1, $2, $3, $4, $5)"
 
-	tx.Exec(`CREATE INDEX IF NOT EXISTS short_url_idx ON urls (short_url)`)
+func (s *PostgresStore) AddURL(ctx context.Context, record URLRecord) error {
+	_, err := s.db.ExecContext(ctx, insertURL,
+		record.OriginalURL, record.ShortURL, record.UserID, record.DeletedFlag, record.ExpiresAt)
+	return conflictError(err)
+}
 
-	err = tx.Commit()
+func conflictError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *PostgresStore) GetURL(ctx context.Context, shortURL string) (string, error) {
+	var record URLRecord
+	var expiresAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, "SELECT original_url, is_deleted, expires_at FROM urls WHERE short_url = $1", shortURL).
+		Scan(&record.OriginalURL, &record.DeletedFlag, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
 	if err != nil {
-		tx.Rollback()
-		logger.Log.Fatalln("Failed to create the database:", err)
+		return "", err
+	}
+	if expiresAt.Valid {
+		record.ExpiresAt = &expiresAt.Time
+	}
+	if err := record.availability(time.Now()); err != nil {
+		return "", err
 	}
+	return record.OriginalURL, nil
+}
 
-	logger.Log.Info("Database initalized successfully")
-	s := &PostgresStore{db: db}
-	return s, nil
+func (s *PostgresStore) GetRecord(ctx context.Context, shortURL string) (URLRecord, error) {
+	var record URLRecord
+	var userID sql.NullString
+	var expiresAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `SELECT id, short_url, original_url, user_id, is_deleted, expires_at FROM urls
+		WHERE short_url = $1`, shortURL).
+		Scan(&record.UUID, &record.ShortURL, &record.OriginalURL, &userID, &record.DeletedFlag, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return URLRecord{}, ErrNotFound
+	}
+	if err != nil {
+		return URLRecord{}, err
+	}
+	record.UserID = userID.String
+	if expiresAt.Valid {
+		record.ExpiresAt = &expiresAt.Time
+	}
+	return record, nil
 }
 
-func (s *PostgresStore) AddURL(ctx context.Context, originalURL, shortURL string) error {
-	_, err := s.db.ExecContext(ctx, "INSERT INTO urls (original_url, short_url) VALUES ($1, $2)", originalURL, shortURL)
+func (s *PostgresStore) GetUserURLs(ctx context.Context, userID string) ([]URLRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, short_url, original_url, user_id FROM urls
+		WHERE user_id = $1 AND NOT is_deleted AND (expires_at IS NULL OR expires_at > now())`, userID)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
-			err = ErrConflict
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []URLRecord
+	for rows.Next() {
+		var record URLRecord
+		if err := rows.Scan(&record.UUID, &record.ShortURL, &record.OriginalURL, &record.UserID); err != nil {
+			return nil, err
 		}
+		records = append(records, record)
 	}
+	return records, rows.Err()
+}
+
+// DeleteURLs помечает удалёнными все ссылки пачки одним UPDATE. Ссылки,
+// принадлежащие другим пользователям, не затрагиваются.
+func (s *PostgresStore) DeleteURLs(ctx context.Context, tasks []DeleteTask) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	shortURLs := make([]string, 0, len(tasks))
+	userIDs := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		shortURLs = append(shortURLs, task.ShortURL)
+		userIDs = append(userIDs, task.UserID)
+	}
+
+	_, err := s.db.ExecContext(ctx, `UPDATE urls SET is_deleted = TRUE
+		FROM unnest($1::text[], $2::text[]) AS d(short_url, user_id)
+		WHERE urls.short_url = d.short_url AND urls.user_id = d.user_id`, shortURLs, userIDs)
 	return err
 }
 
-func (s *PostgresStore) GetURL(ctx context.Context, shortURL string) (string, bool) {
-	var originalURL string
-	err := s.db.QueryRowContext(ctx, "SELECT original_url FROM urls WHERE short_url = $1", shortURL).Scan(&originalURL)
+func (s *PostgresStore) PurgeExpired(ctx context.Context) (int, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM urls WHERE expires_at IS NOT NULL AND expires_at <= now()")
 	if err != nil {
-		return "", false
+		return 0, err
+	}
+	purged, err := res.RowsAffected()
+	return int(purged), err
+}
+
+func (s *PostgresStore) CountURLs(ctx context.Context) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, "SELECT count(*) FROM urls").Scan(&n)
+	return n, err
+}
+
+func (s *PostgresStore) ScanURLs(ctx context.Context, after string, limit int) ([]URLRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, short_url, original_url, user_id, is_deleted, expires_at FROM urls
+		WHERE short_url > $1 ORDER BY short_url LIMIT $2`, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []URLRecord
+	for rows.Next() {
+		var record URLRecord
+		var userID sql.NullString
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&record.UUID, &record.ShortURL, &record.OriginalURL, &userID, &record.DeletedFlag, &expiresAt); err != nil {
+			return nil, err
+		}
+		record.UserID = userID.String
+		if expiresAt.Valid {
+			record.ExpiresAt = &expiresAt.Time
+		}
+		records = append(records, record)
 	}
-	return originalURL, true
+	return records, rows.Err()
 }
 
 func (s *PostgresStore) Ping(ctx context.Context) error {
@@ -80,9 +187,10 @@ func (s *PostgresStore) AddURLBatch(ctx context.Context, urls []URLRecord) error
 	}
 
 	for _, url := range urls {
-		if _, err := tx.ExecContext(ctx, "INSERT INTO urls (original_url, short_url) VALUES ($1, $2)", url.OriginalURL, url.ShortURL); err != nil {
+		if _, err := tx.ExecContext(ctx, insertURL,
+			url.OriginalURL, url.ShortURL, url.UserID, url.DeletedFlag, url.ExpiresAt); err != nil {
 			tx.Rollback()
-			return err
+			return conflictError(err)
 		}
 	}
 
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	stressWorkers    = 16
+	stressIterations = 50
+)
+
+// stressStore параллельно пишет и читает ссылки. Запускать с -race.
+func stressStore(t *testing.T, store URLStore) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for w := 0; w < stressWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < stressIterations; i++ {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				original := "https://example.com/" + id
+				assert.NoError(t, store.AddURL(ctx, URLRecord{ShortURL: id, OriginalURL: original, UserID: "user"}))
+
+				got, err := store.GetURL(ctx, id)
+				assert.NoError(t, err)
+				assert.Equal(t, original, got)
+
+				batch := []URLRecord{
+					{ShortURL: id + "-a", OriginalURL: original + "/a", UserID: "user"},
+					{ShortURL: id + "-b", OriginalURL: original + "/b", UserID: "user"},
+				}
+				assert.NoError(t, store.AddURLBatch(ctx, batch))
+
+				// все воркеры пытаются занять общий идентификатор разными ссылками
+				err = store.AddURL(ctx, URLRecord{ShortURL: "shared", OriginalURL: id})
+				if err != nil {
+					assert.ErrorIs(t, err, ErrConflict)
+				}
+
+				_, err = store.GetUserURLs(ctx, "user")
+				assert.NoError(t, err)
+				_, err = store.CountURLs(ctx)
+				assert.NoError(t, err)
+				_, err = store.ScanURLs(ctx, id, 10)
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	n, err := store.CountURLs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, stressWorkers*stressIterations*3+1, n)
+}
+
+func TestScanURLs(t *testing.T) {
+	file, err := InitFileStore(filepath.Join(t.TempDir(), "urls.json"))
+	require.NoError(t, err)
+	defer file.Close()
+
+	stores := map[string]URLStore{
+		"memory": InitMemoryStore(),
+		"file":   file,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			scanAll := func(limit int) []string {
+				var ids []string
+				after := ""
+				for {
+					page, err := store.ScanURLs(ctx, after, limit)
+					require.NoError(t, err)
+					if len(page) == 0 {
+						return ids
+					}
+					for _, record := range page {
+						ids = append(ids, record.ShortURL)
+					}
+					after = page[len(page)-1].ShortURL
+				}
+			}
+
+			for _, id := range []string{"c", "a", "e"} {
+				require.NoError(t, store.AddURL(ctx, URLRecord{ShortURL: id, OriginalURL: "https://example.com/" + id}))
+			}
+			assert.Equal(t, []string{"a", "c", "e"}, scanAll(2))
+
+			// ключи, добавленные между страницами, попадают в выборку
+			page, err := store.ScanURLs(ctx, "", 1)
+			require.NoError(t, err)
+			require.Len(t, page, 1)
+			require.NoError(t, store.AddURLBatch(ctx, []URLRecord{
+				{ShortURL: "b", OriginalURL: "https://example.com/b"},
+				{ShortURL: "d", OriginalURL: "https://example.com/d"},
+			}))
+			page, err = store.ScanURLs(ctx, "a", 2)
+			require.NoError(t, err)
+			assert.Equal(t, "b", page[0].ShortURL)
+			assert.Equal(t, "c", page[1].ShortURL)
+
+			// after может не совпадать ни с одним ключом
+			page, err = store.ScanURLs(ctx, "bb", 10)
+			require.NoError(t, err)
+			assert.Len(t, page, 3)
+			assert.Equal(t, []string{"a", "b", "c", "d", "e"}, scanAll(10))
+		})
+	}
+}
+
+func TestMemoryStoreConcurrentAccess(t *testing.T) {
+	stressStore(t, InitMemoryStore())
+}
+
+func TestFileStoreConcurrentAccess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.json")
+	store, err := InitFileStore(path)
+	require.NoError(t, err)
+	stressStore(t, store)
+	require.NoError(t, store.Close())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	ids := make(map[int]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record, err := decodeLine(scanner.Bytes())
+		require.NoError(t, err, "line must be a whole record: %s", scanner.Text())
+		assert.False(t, ids[record.UUID], "duplicate UUID %d", record.UUID)
+		ids[record.UUID] = true
+	}
+	require.NoError(t, scanner.Err())
+	assert.Len(t, ids, stressWorkers*stressIterations*3+1)
+
+	reopened, err := InitFileStore(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	n, err := reopened.CountURLs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, len(ids), n)
+}
@@ -1,22 +1,61 @@
 package storage
  
 import (
-	"errors"
 	"context"
+	"errors"
+	"time"
 )
 
 type URLStore interface {
-	AddURL(ctx context.Context, originalURL, shortURL string) error
+	AddURL(ctx context.Context, record URLRecord) error
 	AddURLBatch(ctx context.Context, urls []URLRecord) error
-	GetURL(ctx context.Context, shortURL string) (string, bool)
+	GetURL(ctx context.Context, shortURL string) (string, error)
+	// GetRecord возвращает запись целиком, включая удалённые и просроченные.
+	GetRecord(ctx context.Context, shortURL string) (URLRecord, error)
+	GetUserURLs(ctx context.Context, userID string) ([]URLRecord, error)
+	DeleteURLs(ctx context.Context, tasks []DeleteTask) error
+	PurgeExpired(ctx context.Context) (int, error)
+	CountURLs(ctx context.Context) (int, error)
+	// ScanURLs возвращает до limit записей с идентификатором больше after
+	// в порядке возрастания идентификатора, включая удалённые и просроченные.
+	ScanURLs(ctx context.Context, after string, limit int) ([]URLRecord, error)
 	Ping(ctx context.Context) error
 	Close() error
 }
 
-var ErrConflict = errors.New("data conflict")
+var (
+	ErrConflict = errors.New("data conflict")
+	ErrNotFound = errors.New("url not found")
+	ErrDeleted  = errors.New("url deleted")
+	ErrExpired  = errors.New("url expired")
+)
 
 type URLRecord struct {
-	UUID        int    `json:"uuid"`
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	UUID        int        `json:"uuid"`
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	UserID      string     `json:"user_id,omitempty"`
+	DeletedFlag bool       `json:"is_deleted,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+func (r URLRecord) IsExpired(now time.Time) bool {
+	return r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
+}
+
+// availability возвращает ошибку, если по записи нельзя выполнить переход.
+func (r URLRecord) availability(now time.Time) error {
+	if r.DeletedFlag {
+		return ErrDeleted
+	}
+	if r.IsExpired(now) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// DeleteTask — запрос пользователя на удаление одной из своих ссылок.
+type DeleteTask struct {
+	UserID   string
+	ShortURL string
 }
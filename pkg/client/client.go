@@ -0,0 +1,451 @@
+// Package client — клиент HTTP API сервиса сокращения ссылок.
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	authHeader   = "Authorization"
+	bearerPrefix = "Bearer "
+)
+
+// Client выполняет запросы к сервису. Если токен не задан через WithToken,
+// клиент запоминает токен, выданный сервисом в первом ответе, и дальше
+// действует от имени этого пользователя. Методы безопасны для
+// конкурентного использования.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	gzip       bool
+
+	mu    sync.Mutex
+	token string
+}
+
+type Option func(*Client)
+
+// WithHTTPClient задаёт HTTP-клиент для запросов. По умолчанию используется
+// http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithToken задаёт токен пользователя, полученный ранее из Token.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = strings.TrimPrefix(token, bearerPrefix)
+	}
+}
+
+// WithGzip включает сжатие тел запросов и ответов.
+func WithGzip(enabled bool) Option {
+	return func(c *Client) {
+		c.gzip = enabled
+	}
+}
+
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("base URL must be absolute: %q", baseURL)
+	}
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Token возвращает токен пользователя, от имени которого действует клиент.
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	var data []byte
+	var contentType string
+	switch body := body.(type) {
+	case nil:
+	case string:
+		data, contentType = []byte(body), "text/plain"
+	default:
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+		contentType = "application/json"
+	}
+
+	if c.gzip && data != nil {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	}
+
+	var r io.Reader
+	if data != nil {
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.gzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+		if data != nil {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+	}
+	if token := c.Token(); token != "" {
+		req.Header.Set(authHeader, bearerPrefix+token)
+	}
+	return req, nil
+}
+
+func (c *Client) send(httpClient *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if h := resp.Header.Get(authHeader); strings.HasPrefix(h, bearerPrefix) {
+		c.mu.Lock()
+		if c.token == "" {
+			c.token = strings.TrimPrefix(h, bearerPrefix)
+		}
+		c.mu.Unlock()
+	}
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("decompress response: %w", err)
+		}
+		resp.Body = gzipBody{Reader: zr, body: resp.Body}
+	}
+	return resp, nil
+}
+
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b gzipBody) Close() error {
+	return b.body.Close()
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(c.httpClient, req)
+}
+
+// apiError читает из ответа описание ошибки.
+func apiError(resp *http.Response) *APIError {
+	e := &APIError{StatusCode: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	// ошибки сервис описывает в problem+json по RFC 7807
+	var body struct {
+		Code   string `json:"code"`
+		Detail string `json:"detail"`
+	}
+	if json.Unmarshal(data, &body) != nil || body.Code == "" {
+		e.Message = strings.TrimSpace(string(data))
+		return e
+	}
+	e.Code, e.Message = body.Code, body.Detail
+	return e
+}
+
+func decode(resp *http.Response, v any) error {
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// shortID принимает идентификатор или короткую ссылку целиком.
+func shortID(s string) string {
+	return s[strings.LastIndex(s, "/")+1:]
+}
+
+// ShortenRequest описывает сокращаемую ссылку. Alias задаёт собственный
+// идентификатор; ExpiresAt и TTL ограничивают срок жизни ссылки, задать
+// можно не больше одного из них.
+type ShortenRequest struct {
+	URL       string
+	Alias     string
+	ExpiresAt *time.Time
+	TTL       time.Duration
+}
+
+type shortenOptions struct {
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds int64      `json:"ttl_seconds,omitempty"`
+	Alias      string     `json:"alias,omitempty"`
+}
+
+func (r ShortenRequest) options() shortenOptions {
+	return shortenOptions{
+		ExpiresAt:  r.ExpiresAt,
+		TTLSeconds: int64(r.TTL / time.Second),
+		Alias:      r.Alias,
+	}
+}
+
+type shortenBody struct {
+	URL string `json:"url"`
+	shortenOptions
+}
+
+// Shorten сокращает ссылку и возвращает короткую ссылку. Если ссылка уже
+// была сокращена, возвращается *ConflictError с существующей короткой ссылкой.
+func (c *Client) Shorten(ctx context.Context, req ShortenRequest) (string, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/api/shorten", shortenBody{URL: req.URL, shortenOptions: req.options()})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusConflict {
+		return "", apiError(resp)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Result string `json:"result"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil || result.Result == "" {
+		// 409 без короткой ссылки — идентификатор занят другой ссылкой
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		return "", apiError(resp)
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return result.Result, &ConflictError{ShortURL: result.Result}
+	}
+	return result.Result, nil
+}
+
+type BatchItem struct {
+	CorrelationID string
+	ShortenRequest
+}
+
+type batchBody struct {
+	CorrelationID string `json:"correlation_id"`
+	OriginalURL   string `json:"original_url"`
+	shortenOptions
+}
+
+// ShortenBatch сокращает несколько ссылок и возвращает короткие ссылки
+// по correlation ID. Уже сокращённые ссылки получают прежние короткие ссылки.
+func (c *Client) ShortenBatch(ctx context.Context, items []BatchItem) (map[string]string, error) {
+	body := make([]batchBody, 0, len(items))
+	for _, item := range items {
+		body = append(body, batchBody{
+			CorrelationID:  item.CorrelationID,
+			OriginalURL:    item.URL,
+			shortenOptions: item.options(),
+		})
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/api/shorten/batch", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, apiError(resp)
+	}
+	var result []struct {
+		CorrelationID string `json:"correlation_id"`
+		ShortURL      string `json:"short_url"`
+	}
+	if err := decode(resp, &result); err != nil {
+		return nil, err
+	}
+	urls := make(map[string]string, len(result))
+	for _, item := range result {
+		urls[item.CorrelationID] = item.ShortURL
+	}
+	return urls, nil
+}
+
+// Resolve возвращает исходную ссылку по идентификатору или короткой ссылке.
+// Для удалённых и просроченных ссылок возвращается ошибка ErrGone.
+func (c *Client) Resolve(ctx context.Context, id string) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/"+url.PathEscape(shortID(id)), nil)
+	if err != nil {
+		return "", err
+	}
+	noRedirect := *c.httpClient
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := c.send(&noRedirect, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTemporaryRedirect {
+		return "", apiError(resp)
+	}
+	return resp.Header.Get("Location"), nil
+}
+
+type URL struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+}
+
+// List возвращает ссылки, сокращённые пользователем клиента.
+func (c *Client) List(ctx context.Context) ([]URL, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/api/user/urls", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return []URL{}, nil
+	case http.StatusOK:
+	default:
+		return nil, apiError(resp)
+	}
+	var urls []URL
+	if err := decode(resp, &urls); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// Delete помечает ссылки пользователя удалёнными. Сервис удаляет их
+// асинхронно, поэтому сразу после вызова ссылки ещё могут открываться.
+func (c *Client) Delete(ctx context.Context, ids ...string) error {
+	body := make([]string, 0, len(ids))
+	for _, id := range ids {
+		body = append(body, shortID(id))
+	}
+	resp, err := c.do(ctx, http.MethodDelete, "/api/user/urls", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return apiError(resp)
+	}
+	return nil
+}
+
+type DayClicks struct {
+	Date   string `json:"date"`
+	Clicks int    `json:"clicks"`
+}
+
+type ReferrerClicks struct {
+	Referrer string `json:"referrer"`
+	Clicks   int    `json:"clicks"`
+}
+
+type Stats struct {
+	ShortURL       string           `json:"short_url"`
+	TotalClicks    int              `json:"total_clicks"`
+	UniqueVisitors int              `json:"unique_visitors"`
+	ClicksPerDay   []DayClicks      `json:"clicks_per_day"`
+	TopReferrers   []ReferrerClicks `json:"top_referrers"`
+}
+
+// Stats возвращает статистику переходов по ссылке пользователя.
+func (c *Client) Stats(ctx context.Context, id string) (Stats, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/api/stats/"+url.PathEscape(shortID(id)), nil)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Stats{}, apiError(resp)
+	}
+	var stats Stats
+	if err := decode(resp, &stats); err != nil {
+		return Stats{}, err
+	}
+	return stats, nil
+}
+
+// ExportFormat — формат выгрузки ссылок.
+type ExportFormat string
+
+const (
+	ExportNDJSON ExportFormat = "application/x-ndjson"
+	ExportCSV    ExportFormat = "text/csv"
+)
+
+// Export выгружает ссылки пользователя в w в заданном формате так, как их
+// отдаёт сервис, не разбирая ответ.
+func (c *Client) Export(ctx context.Context, w io.Writer, format ExportFormat) error {
+	req, err := c.newRequest(ctx, http.MethodGet, "/api/export", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", string(format))
+	resp, err := c.send(c.httpClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// IsConflict сообщает, что ссылка уже была сокращена, и возвращает
+// существующую короткую ссылку.
+func IsConflict(err error) (string, bool) {
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		return conflict.ShortURL, true
+	}
+	return "", false
+}
@@ -0,0 +1,124 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ma-shulgin/go-link-shortener/internal/app"
+	"github.com/ma-shulgin/go-link-shortener/internal/storage"
+	"github.com/ma-shulgin/go-link-shortener/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	store, err := storage.InitBoltStore(filepath.Join(t.TempDir(), "urls.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	// базовый адрес сервиса известен только после запуска сервера
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	mux.Handle("/", app.RootRouter(store, ts.URL))
+	return ts
+}
+
+func TestClient(t *testing.T) {
+	for _, gzip := range []bool{false, true} {
+		name := "plain"
+		if gzip {
+			name = "gzip"
+		}
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ts := newServer(t)
+			c, err := client.New(ts.URL, client.WithHTTPClient(ts.Client()), client.WithGzip(gzip))
+			require.NoError(t, err)
+
+			shortURL, err := c.Shorten(ctx, client.ShortenRequest{URL: "https://example.com/a"})
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(shortURL, ts.URL+"/"))
+			token := c.Token()
+			require.NotEmpty(t, token, "client must remember the issued token")
+
+			existing, err := c.Shorten(ctx, client.ShortenRequest{URL: "https://example.com/a"})
+			assert.ErrorIs(t, err, client.ErrConflict)
+			got, ok := client.IsConflict(err)
+			assert.True(t, ok)
+			assert.Equal(t, shortURL, got)
+			assert.Equal(t, shortURL, existing)
+
+			_, err = c.Shorten(ctx, client.ShortenRequest{URL: "https://example.com/b", Alias: "promo", TTL: time.Hour})
+			require.NoError(t, err)
+			_, err = c.Shorten(ctx, client.ShortenRequest{URL: "https://example.com/c", Alias: "promo"})
+			var apiErr *client.APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, "alias_taken", apiErr.Code)
+			_, ok = client.IsConflict(err)
+			assert.False(t, ok)
+
+			_, err = c.Shorten(ctx, client.ShortenRequest{URL: "javascript:alert(1)"})
+			assert.ErrorIs(t, err, client.ErrBadRequest)
+			_, err = c.Shorten(ctx, client.ShortenRequest{URL: ""})
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, "invalid_request", apiErr.Code)
+
+			batch, err := c.ShortenBatch(ctx, []client.BatchItem{
+				{CorrelationID: "1", ShortenRequest: client.ShortenRequest{URL: "https://example.com/1"}},
+				{CorrelationID: "2", ShortenRequest: client.ShortenRequest{URL: "https://example.com/2", Alias: "second"}},
+			})
+			require.NoError(t, err)
+			assert.Len(t, batch, 2)
+			assert.Equal(t, ts.URL+"/second", batch["2"])
+
+			original, err := c.Resolve(ctx, shortURL)
+			require.NoError(t, err)
+			assert.Equal(t, "https://example.com/a", original)
+
+			urls, err := c.List(ctx)
+			require.NoError(t, err)
+			assert.Len(t, urls, 4)
+
+			var export strings.Builder
+			require.NoError(t, c.Export(ctx, &export, client.ExportCSV))
+			assert.Equal(t, 5, strings.Count(export.String(), "\n"), "header and four links")
+
+			stats, err := c.Stats(ctx, shortURL)
+			require.NoError(t, err)
+			assert.Equal(t, shortURL, stats.ShortURL)
+
+			// другой пользователь не видит чужих ссылок
+			other, err := client.New(ts.URL, client.WithHTTPClient(ts.Client()))
+			require.NoError(t, err)
+			_, err = other.List(ctx)
+			assert.ErrorIs(t, err, client.ErrUnauthorized)
+			_, err = other.Stats(ctx, shortURL)
+			assert.ErrorIs(t, err, client.ErrForbidden)
+
+			// сохранённый токен восстанавливает пользователя
+			same, err := client.New(ts.URL, client.WithHTTPClient(ts.Client()), client.WithToken(token))
+			require.NoError(t, err)
+			require.NoError(t, same.Delete(ctx, "promo"))
+			assert.Eventually(t, func() bool {
+				_, err := c.Resolve(ctx, "promo")
+				return err != nil
+			}, 3*time.Second, 20*time.Millisecond)
+			_, err = c.Resolve(ctx, "promo")
+			assert.ErrorIs(t, err, client.ErrGone)
+			_, err = c.Resolve(ctx, "missing")
+			assert.ErrorIs(t, err, client.ErrNotFound)
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := client.New("localhost:8080")
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrGone         = errors.New("short URL is deleted or expired")
+	ErrTooLarge     = errors.New("request too large")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrBlocked      = errors.New("destination is blocked")
+)
+
+// APIError — ответ сервиса с кодом ошибки. Через errors.Is сравнивается
+// с ErrNotFound, ErrGone и другими ошибками пакета по коду ответа.
+type APIError struct {
+	StatusCode int
+	// Code и Message заполняются, если сервис вернул описание ошибки в JSON.
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.Code != "" {
+		return fmt.Sprintf("shortener: %d %s: %s", e.StatusCode, e.Code, msg)
+	}
+	return fmt.Sprintf("shortener: %d: %s", e.StatusCode, msg)
+}
+
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusGone:
+		return ErrGone
+	case http.StatusRequestEntityTooLarge:
+		return ErrTooLarge
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusUnavailableForLegalReasons:
+		return ErrBlocked
+	}
+	return nil
+}
+
+// ConflictError возвращается, если ссылка уже была сокращена. ShortURL
+// содержит существующую короткую ссылку.
+type ConflictError struct {
+	ShortURL string
+}
+
+func (e *ConflictError) Error() string {
+	return "shortener: URL is already shortened as " + e.ShortURL
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}